@@ -1,17 +1,43 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"os"
 
 	"github.com/choix/slang/s"
 	"github.com/peterh/liner"
 )
 
 func main() {
+	compile := flag.String("compile", "", "compile given source file into a .slangc module")
+	disasm := flag.String("disasm", "", "print bytecode of given source or compiled module")
+	flag.Parse()
+
+	switch {
+	case *compile != "":
+		if err := s.CompileFile(*compile, *compile+"c"); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+
+	case *disasm != "":
+		if err := disassemble(*disasm); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	line := liner.NewLiner()
 	defer line.Close()
 
+	interp := s.NewInterpreter(s.Options{Path: []string{"."}})
+
 	fmt.Println("Slang REPL (Ctrl-D to quit)")
 	for {
 		input, err := line.Prompt("slang > ")
@@ -24,11 +50,32 @@ func main() {
 			return
 		}
 
-		output, err := s.Rep(input)
+		output, err := interp.Rep(input)
 		if err != nil {
 			fmt.Println("error:", err)
+
+			var evalErr *s.EvalError
+			if errors.As(err, &evalErr) {
+				fmt.Println(evalErr.Trace())
+			}
 		} else {
 			fmt.Println(output)
 		}
 	}
 }
+
+func disassemble(path string) error {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	m, err := s.ReadModule(bytes.NewReader(code))
+	if err != nil {
+		if m, err = s.CompileModule(string(code), path); err != nil {
+			return err
+		}
+	}
+
+	return m.Disassemble(os.Stdout)
+}
@@ -0,0 +1,435 @@
+package s
+
+import "fmt"
+
+// Special forms are evaluated by the evaluator itself, their arguments are
+// not evaluated up front and their names cannot be shadowed by locals.
+var specialForms = map[string]bool{
+	"fn": true, "set": true, "let": true, "do": true, "if": true,
+	"when": true, "unless": true, "and": true, "or": true, "cond": true,
+	"case": true, "try": true, "ns": true, "require": true, "refer": true,
+}
+
+// Thunk forms pass their body as a function of no arguments to a builtin
+var thunkForms = map[string]string{
+	"go":       "spawn",
+	"lazy-seq": "lazy-seq*",
+}
+
+// scope tracks locals of one function frame during analysis
+type scope struct {
+	parent *scope
+	locals []local // visible bindings, innermost last
+	slots  int
+}
+
+type local struct {
+	name  string
+	index int
+}
+
+// declare allocates a new slot for name in the frame
+func (sc *scope) declare(name string) Local {
+	index := sc.slots
+	sc.slots++
+	sc.locals = append(sc.locals, local{name: name, index: index})
+	return Local{Name: name, Index: index}
+}
+
+// resolve returns address of a visible local, globals are not resolved
+func (sc *scope) resolve(name string) (Local, bool) {
+	depth := 0
+	for s := sc; s != nil; s = s.parent {
+		for i := len(s.locals) - 1; i >= 0; i-- {
+			if s.locals[i].name == name {
+				return Local{Name: name, Depth: depth, Index: s.locals[i].index}, true
+			}
+		}
+		depth++
+	}
+	return Local{}, false
+}
+
+// analyze resolves every local variable of form to its frame and slot.
+// Returned number of slots must be allocated in a frame before the
+// result is evaluated, names which are not local stay symbols and are
+// looked up in the global environment at runtime.
+func analyze(form Item) (Item, int, error) {
+	sc := &scope{}
+	node, err := sc.analyze(form)
+	if err != nil {
+		return nil, 0, err
+	}
+	return node, sc.slots, nil
+}
+
+func (sc *scope) analyze(form Item) (Item, error) {
+	switch v := form.(type) {
+	case Symbol:
+		if l, ok := sc.resolve(v.Value); ok {
+			return l, nil
+		}
+		return v, nil
+
+	case List:
+		return sc.analyzeList(v)
+
+	default:
+		return form, nil
+	}
+}
+
+func (sc *scope) analyzeAll(forms []Item) ([]Item, error) {
+	nodes := make([]Item, len(forms))
+	for i, form := range forms {
+		node, err := sc.analyze(form)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+func (sc *scope) analyzeList(list List) (Item, error) {
+	if len(list.Value) == 0 {
+		return list, nil
+	}
+
+	head, ok := list.Value[0].(Symbol)
+	if ok && head.Value == "." {
+		return sc.analyzeMember(list)
+	}
+	if builtin, ok := thunkForms[head.Value]; ok {
+		// (go body...) is (spawn (fn [] body...))
+		fn := rebuild(list, append([]Item{NewSymbol("fn"), Vector{}}, list.Value[1:]...))
+		return sc.analyze(rebuild(list, []Item{NewSymbol(builtin), fn}))
+	}
+	if !ok || !specialForms[head.Value] {
+		// Function application
+		nodes, err := sc.analyzeAll(list.Value)
+		if err != nil {
+			return nil, err
+		}
+		return rebuild(list, nodes), nil
+	}
+
+	rest := list.Value[1:]
+	var nodes []Item
+	var err error
+
+	switch head.Value {
+	case "fn":
+		return sc.analyzeFn(rest)
+
+	case "set":
+		if len(rest) != 2 {
+			return nil, fmt.Errorf("set expects a name and a value")
+		}
+		if _, ok := rest[0].(Symbol); !ok {
+			return nil, fmt.Errorf("set expects a symbol name, got %s", printKey(rest[0]))
+		}
+
+		value, err := sc.analyze(rest[1])
+		if err != nil {
+			return nil, err
+		}
+		nodes = []Item{rest[0], value}
+
+	case "if":
+		if len(rest) < 2 {
+			return nil, fmt.Errorf("if expects a condition and a branch")
+		}
+		if len(rest) > 3 {
+			return nil, fmt.Errorf("if expects at most two branches, got %d", len(rest)-1)
+		}
+		nodes, err = sc.analyzeAll(rest)
+
+	case "let":
+		nodes, err = sc.analyzeLet(rest)
+
+	case "case":
+		nodes, err = sc.analyzeCase(rest)
+
+	case "try":
+		nodes, err = sc.analyzeTry(rest)
+
+	case "ns", "require", "refer":
+		return list, nil
+
+	default:
+		nodes, err = sc.analyzeAll(rest)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return rebuild(list, append([]Item{head}, nodes...)), nil
+}
+
+// rebuild returns list with new items keeping its source position
+func rebuild(list List, items []Item) List {
+	return List{Value: items, pos: list.pos}
+}
+
+// analyzeMember turns `(. obj Member args...)` into a call of the `.`
+// builtin with the member name as a string
+func (sc *scope) analyzeMember(list List) (Item, error) {
+	rest := list.Value[1:]
+	if len(rest) < 2 {
+		return nil, fmt.Errorf(". expects an object and a member name")
+	}
+	member, ok := rest[1].(Symbol)
+	if !ok {
+		return nil, fmt.Errorf(". expects a member name, got %s", printKey(rest[1]))
+	}
+
+	obj, err := sc.analyze(rest[0])
+	if err != nil {
+		return nil, err
+	}
+	args, err := sc.analyzeAll(rest[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := append([]Item{list.Value[0], obj, String{Value: member.Value}}, args...)
+	return rebuild(list, nodes), nil
+}
+
+func (sc *scope) analyzeFn(rest []Item) (Item, error) {
+	if len(rest) == 0 {
+		return nil, fmt.Errorf("fn expects a vector of params")
+	}
+	params, ok := rest[0].(Vector)
+	if !ok {
+		return nil, fmt.Errorf("fn expects a vector of params, got %s", printKey(rest[0]))
+	}
+
+	fn := Lambda{}
+
+	// Count required params to validate arity on call, :as binds the
+	// whole argument list and takes no argument of its own
+	for _, p := range params.Value {
+		if sym, ok := p.(Symbol); ok && sym.Value == "&" {
+			fn.Variadic = true
+			break
+		}
+		if kw, ok := p.(Keyword); ok && kw.Value == "as" {
+			break
+		}
+		fn.Required++
+	}
+
+	fnScope := &scope{parent: sc}
+	pattern, err := fnScope.analyzePattern(params)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := fnScope.analyzeAll(rest[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	fn.Params = pattern.(Vector)
+	fn.Body = body
+	fn.Slots = fnScope.slots
+	return fn, nil
+}
+
+// analyzeLet turns both binding forms into `(let [pattern value ...] body...)`
+func (sc *scope) analyzeLet(rest []Item) ([]Item, error) {
+	if len(rest) == 0 {
+		return nil, fmt.Errorf("let expects bindings")
+	}
+
+	var pairs []Item
+	switch vars := rest[0].(type) {
+	case Vector:
+		if len(vars.Value)%2 != 0 {
+			return nil, fmt.Errorf("let expects an even number of forms in bindings")
+		}
+		pairs = vars.Value
+
+	case Hash:
+		// Deprecated: hash bindings are kept for compatibility only, use
+		// `(let [a 1 b 2] ...)` which guarantees sequential binding.
+		for _, kv := range vars.Value {
+			pairs = append(pairs, kv.Key, kv.Value)
+		}
+
+	default:
+		return nil, fmt.Errorf("let expects a vector of bindings, got %s", printKey(rest[0]))
+	}
+
+	// Bindings are visible only inside of let
+	mark := len(sc.locals)
+	defer func() { sc.locals = sc.locals[:mark] }()
+
+	bindings := Vector{Value: make([]Item, len(pairs))}
+	for i := 0; i < len(pairs); i += 2 {
+		// Value is analyzed first, so it sees only previous names
+		value, err := sc.analyze(pairs[i+1])
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := sc.analyzePattern(pairs[i])
+		if err != nil {
+			return nil, err
+		}
+		bindings.Value[i], bindings.Value[i+1] = pattern, value
+	}
+
+	body, err := sc.analyzeAll(rest[1:])
+	if err != nil {
+		return nil, err
+	}
+	return append([]Item{bindings}, body...), nil
+}
+
+func (sc *scope) analyzeCase(rest []Item) ([]Item, error) {
+	if len(rest) == 0 {
+		return nil, fmt.Errorf("missing case expression")
+	}
+
+	nodes := make([]Item, len(rest))
+	for i, form := range rest {
+		// Constants are left as they are
+		if i > 0 && i%2 == 1 && i != len(rest)-1 {
+			nodes[i] = form
+			continue
+		}
+
+		node, err := sc.analyze(form)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+func (sc *scope) analyzeTry(rest []Item) ([]Item, error) {
+	nodes := make([]Item, len(rest))
+	for i, form := range rest {
+		if list, ok := form.(List); ok && len(list.Value) > 1 {
+			if sym, ok := list.Value[0].(Symbol); ok && sym.Value == "catch" {
+				mark := len(sc.locals)
+				pattern, err := sc.analyzePattern(list.Value[1])
+				if err != nil {
+					return nil, err
+				}
+				handler, err := sc.analyzeAll(list.Value[2:])
+				if err != nil {
+					return nil, err
+				}
+				sc.locals = sc.locals[:mark]
+
+				nodes[i] = rebuild(list, append([]Item{sym, pattern}, handler...))
+				continue
+			}
+		}
+
+		node, err := sc.analyze(form)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+// analyzePattern declares every name bound by a destructuring pattern,
+// see bind for supported patterns
+func (sc *scope) analyzePattern(pattern Item) (Item, error) {
+	switch p := pattern.(type) {
+	case Symbol:
+		if p.Value == "&" {
+			return p, nil
+		}
+		return sc.declare(p.Value), nil
+
+	case Vector:
+		out := Vector{Value: make([]Item, len(p.Value))}
+		for i, item := range p.Value {
+			if kw, ok := item.(Keyword); ok && kw.Value == "as" {
+				out.Value[i] = item
+				continue
+			}
+
+			node, err := sc.analyzePattern(item)
+			if err != nil {
+				return nil, err
+			}
+			out.Value[i] = node
+		}
+		return out, nil
+
+	case Hash:
+		out := Hash{}
+
+		// Defaults see only names bound before the pattern
+		if or, ok := p.Get(NewKeyword("or")); ok {
+			defaults, ok := or.(Hash)
+			if !ok {
+				return nil, fmt.Errorf(":or expects a hash, got %s", printKey(or))
+			}
+
+			analyzed := Hash{}
+			for _, kv := range defaults.Value {
+				value, err := sc.analyze(kv.Value)
+				if err != nil {
+					return nil, err
+				}
+				analyzed = analyzed.Add(KeyValue{Key: kv.Key, Value: value})
+			}
+			out = out.Add(KeyValue{Key: NewKeyword("or"), Value: analyzed})
+		}
+
+		for _, kv := range p.Value {
+			if kw, ok := kv.Key.(Keyword); ok {
+				switch kw.Value {
+				case "or":
+					continue
+
+				case "keys", "strs", "syms":
+					names, ok := kv.Value.(Vector)
+					if !ok {
+						return nil, fmt.Errorf(":%s expects a vector, got %s", kw.Value, printKey(kv.Value))
+					}
+
+					locals := Vector{Value: make([]Item, len(names.Value))}
+					for i, n := range names.Value {
+						sym, ok := n.(Symbol)
+						if !ok {
+							return nil, fmt.Errorf(":%s expects symbols, got %s", kw.Value, printKey(n))
+						}
+						locals.Value[i] = sc.declare(sym.Value)
+					}
+					out = out.Add(KeyValue{Key: kw, Value: locals})
+					continue
+
+				case "as":
+					node, err := sc.analyzePattern(kv.Value)
+					if err != nil {
+						return nil, err
+					}
+					out = out.Add(KeyValue{Key: kw, Value: node})
+					continue
+				}
+			}
+
+			// {local-pattern lookup-key}
+			node, err := sc.analyzePattern(kv.Key)
+			if err != nil {
+				return nil, err
+			}
+			out = out.Add(KeyValue{Key: node, Value: kv.Value})
+		}
+		return out, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported binding form %s", printKey(pattern))
+	}
+}
@@ -0,0 +1,117 @@
+package s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	form, err := NewReader().Parse("(let [a 1] (fn [b & [c]] (+ a b c x)))")
+	assert.NoError(t, err)
+
+	node, slots, err := analyze(form)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, slots)
+
+	let := node.(List)
+	assert.Equal(t, Vector{Value: []Item{
+		Local{Name: "a", Index: 0},
+		Integer{Value: 1},
+	}}, let.Value[1])
+
+	fn := let.Value[2].(Lambda)
+	assert.Equal(t, 2, fn.Slots)
+	assert.Equal(t, 1, fn.Required)
+	assert.True(t, fn.Variadic)
+	assert.Equal(t, Vector{Value: []Item{
+		Local{Name: "b", Index: 0},
+		NewSymbol("&"),
+		Vector{Value: []Item{Local{Name: "c", Index: 1}}},
+	}}, fn.Params)
+	assert.Equal(t, []Item{List{Value: []Item{
+		NewSymbol("+"),
+		Local{Name: "a", Depth: 1, Index: 0},
+		Local{Name: "b", Index: 0},
+		Local{Name: "c", Index: 1},
+		NewSymbol("x"),
+	}}}, []Item{stripPositions(fn.Body[0])})
+}
+
+func TestAnalyze_Errors(t *testing.T) {
+	cases := map[string]string{
+		"(fn a a)":         "fn expects a vector of params, got a",
+		"(let [a] a)":      "let expects an even number of forms in bindings",
+		"(let [1 2] 3)":    "unsupported binding form 1",
+		"(set 1 2)":        "set expects a symbol name, got 1",
+		"(let a a)":        "let expects a vector of bindings, got a",
+		"(fn [{:keys a}])": ":keys expects a vector, got a",
+	}
+
+	for code, msg := range cases {
+		form, err := NewReader().Parse(code)
+		assert.NoError(t, err)
+
+		_, _, err = analyze(form)
+		assert.EqualError(t, err, msg, code)
+	}
+}
+
+func TestRep_Scoping(t *testing.T) {
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{"(let [a 1 f (fn [] a) a 2] (+ (f) a))", "3"},
+		{"(let [a 1] (let [a 2] a))", "2"},
+		{"(let [a 1] (let [b 2] a) a)", "1"},
+		{"(let [x 1] ((fn [x] x) 2))", "2"},
+		{"(((fn [a] (fn [] (let [b 2] (+ a b)))) 1))", "3"},
+		{"(try (throw 1) (catch e (let [f (fn [] e)] (f))))", "1"},
+		{"(let [a 5] (case a 5 a 6 :six))", "5"},
+		{"(set scope-set (fn [] (let [x 1] (set scope-leaked x))))", "function"},
+		{"(scope-set)", "1"},
+		{"scope-leaked", "1"},
+		{"(let [a 1] (set scope-copy a))", "1"},
+		{"(let [p 80 {:keys [port] :or {port p}} {}] port)", "80"},
+	}
+
+	for _, c := range cases {
+		res, err := Rep(c.input)
+		assert.NoError(t, err)
+		assert.Equal(t, c.output, res, "%s should return %s", c.input, c.output)
+	}
+}
+
+func benchmarkEval(b *testing.B, opts Options, setup string, code string) {
+	in := NewInterpreter(opts)
+	if _, err := in.EvalString(setup); err != nil {
+		b.Fatal(err)
+	}
+
+	form, err := NewReader().Parse(code)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := in.Eval(form); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEval_Fib(b *testing.B) {
+	benchmarkEval(b, Options{}, "(set fib (fn [n] (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2))))))", "(fib 15)")
+}
+
+func BenchmarkEval_Locals(b *testing.B) {
+	benchmarkEval(b, Options{}, `
+(set adder (fn [a b c]
+  (let [d (+ a b)
+        e (+ d c)
+        f (fn [x] (+ a b c d e x))]
+    (+ (f 1) (f 2) (f 3)))))`, "(adder 1 2 3)")
+}
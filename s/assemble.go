@@ -0,0 +1,414 @@
+package s
+
+import "fmt"
+
+// Compile analyzes form and compiles it into a chunk for the VM
+func Compile(form Item) (*Chunk, error) {
+	node, slots, err := analyze(form)
+	if err != nil {
+		return nil, err
+	}
+
+	return assembleMain(node, slots)
+}
+
+// assembleMain compiles analyzed top level form needing given slots
+func assembleMain(node Item, slots int) (*Chunk, error) {
+	chunk, err := assemble("main", nil, []Item{node})
+	if err != nil {
+		return nil, err
+	}
+	chunk.Slots = slots
+	return chunk, nil
+}
+
+// assembler emits code of a single chunk
+type assembler struct {
+	chunk *Chunk
+}
+
+// assemble compiles analyzed body into a new chunk
+func assemble(name string, params Item, body []Item) (*Chunk, error) {
+	a := &assembler{chunk: &Chunk{Name: name, Params: params}}
+	if err := a.body(body); err != nil {
+		return nil, err
+	}
+	a.emit(OpReturn)
+
+	if len(a.chunk.Code) > 0xffff {
+		return nil, fmt.Errorf("chunk %s is too large", name)
+	}
+	return a.chunk, nil
+}
+
+// emit appends instruction and returns its address
+func (a *assembler) emit(op Opcode, operands ...int) int {
+	at := len(a.chunk.Code)
+	a.chunk.Code = append(a.chunk.Code, byte(op))
+	for _, o := range operands {
+		a.chunk.Code = append(a.chunk.Code, byte(o>>8), byte(o))
+	}
+	return at
+}
+
+// patch sets operand i of instruction at given address to current end of
+// code, so a forward jump lands after everything emitted so far
+func (a *assembler) patch(at int, i int) {
+	target := len(a.chunk.Code)
+	a.chunk.Code[at+1+2*i] = byte(target >> 8)
+	a.chunk.Code[at+2+2*i] = byte(target)
+}
+
+func (a *assembler) constant(item Item) (int, error) {
+	if len(a.chunk.Consts) >= noChunk {
+		return 0, fmt.Errorf("too many constants in chunk %s", a.chunk.Name)
+	}
+	a.chunk.Consts = append(a.chunk.Consts, item)
+	return len(a.chunk.Consts) - 1, nil
+}
+
+// emitConst emits instruction taking a new constant as its first operand
+func (a *assembler) emitConst(op Opcode, item Item, operands ...int) (int, error) {
+	k, err := a.constant(item)
+	if err != nil {
+		return 0, err
+	}
+	return a.emit(op, append([]int{k}, operands...)...), nil
+}
+
+// body compiles forms evaluated in order, leaving only the last result
+func (a *assembler) body(nodes []Item) error {
+	if len(nodes) == 0 {
+		_, err := a.emitConst(OpConst, Nil{})
+		return err
+	}
+
+	for i, node := range nodes {
+		if i > 0 {
+			a.emit(OpPop)
+		}
+		if err := a.node(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *assembler) node(node Item) error {
+	switch v := node.(type) {
+	case Symbol:
+		_, err := a.emitConst(OpGlobal, v)
+		return err
+
+	case Local:
+		_, err := a.emitConst(OpLocal, v)
+		return err
+
+	case Lambda:
+		return a.lambda("fn", v)
+
+	case List:
+		return a.list(v)
+
+	default:
+		_, err := a.emitConst(OpConst, v)
+		return err
+	}
+}
+
+func (a *assembler) lambda(name string, fn Lambda) error {
+	chunk, err := assemble(name, fn.Params, fn.Body)
+	if err != nil {
+		return err
+	}
+	chunk.Slots = fn.Slots
+	chunk.Required = fn.Required
+	chunk.Variadic = fn.Variadic
+
+	_, err = a.emitConst(OpClosure, chunk)
+	return err
+}
+
+func (a *assembler) list(list List) error {
+	if len(list.Value) == 0 {
+		_, err := a.emitConst(OpConst, list)
+		return err
+	}
+
+	head, ok := list.Value[0].(Symbol)
+	if !ok || !specialForms[head.Value] {
+		return a.call(list)
+	}
+
+	rest := list.Value[1:]
+	switch head.Value {
+	case "set":
+		return a.set(rest)
+
+	case "let":
+		return a.let(rest)
+
+	case "do":
+		return a.body(rest)
+
+	case "if":
+		return a.ifForm(rest)
+
+	case "when":
+		return a.when(rest, true)
+
+	case "unless":
+		return a.when(rest, false)
+
+	case "and":
+		return a.logic(rest, OpJumpFalseKeep, True{})
+
+	case "or":
+		return a.logic(rest, OpJumpTrueKeep, Nil{})
+
+	case "cond":
+		return a.cond(rest)
+
+	case "case":
+		return a.caseForm(rest)
+
+	case "try":
+		return a.try(rest)
+
+	case "ns":
+		return fmt.Errorf("ns is only allowed at top level")
+
+	case "require":
+		_, err := a.emitConst(OpRequire, List{Value: rest})
+		return err
+
+	case "refer":
+		_, err := a.emitConst(OpRefer, List{Value: rest})
+		return err
+
+	default:
+		return fmt.Errorf("cannot compile special form %s", head.Value)
+	}
+}
+
+func (a *assembler) call(list List) error {
+	for _, node := range list.Value {
+		if err := a.node(node); err != nil {
+			return err
+		}
+	}
+
+	site := Frame{Name: callName(list.Value[0])}
+	if pos, ok := PositionOf(list); ok {
+		site.Position = pos
+	}
+	a.chunk.Calls = append(a.chunk.Calls, site)
+
+	a.emit(OpCall, len(list.Value)-1, len(a.chunk.Calls)-1)
+	return nil
+}
+
+func (a *assembler) set(rest []Item) error {
+	name := rest[0].(Symbol)
+
+	var err error
+	if fn, ok := rest[1].(Lambda); ok {
+		err = a.lambda(name.Value, fn)
+	} else {
+		err = a.node(rest[1])
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = a.emitConst(OpDefine, name)
+	return err
+}
+
+func (a *assembler) let(rest []Item) error {
+	bindings := rest[0].(Vector).Value
+	for i := 0; i < len(bindings); i += 2 {
+		if err := a.node(bindings[i+1]); err != nil {
+			return err
+		}
+		if _, err := a.emitConst(OpBind, bindings[i]); err != nil {
+			return err
+		}
+	}
+	return a.body(rest[1:])
+}
+
+func (a *assembler) ifForm(rest []Item) error {
+	if len(rest) < 2 {
+		return fmt.Errorf("if expects a condition and a branch")
+	}
+
+	var ifFalse Item = Nil{}
+	if len(rest) == 3 {
+		ifFalse = rest[2]
+	}
+	return a.branch(rest[0], rest[1:2], []Item{ifFalse})
+}
+
+func (a *assembler) when(rest []Item, expected bool) error {
+	if len(rest) == 0 {
+		return fmt.Errorf("missing condition")
+	}
+
+	if expected {
+		return a.branch(rest[0], rest[1:], []Item{Nil{}})
+	}
+	return a.branch(rest[0], []Item{Nil{}}, rest[1:])
+}
+
+// branch compiles `if` with bodies for both outcomes of cond
+func (a *assembler) branch(cond Item, ifTrue, ifFalse []Item) error {
+	if err := a.node(cond); err != nil {
+		return err
+	}
+	toFalse := a.emit(OpJumpFalse, 0)
+
+	if err := a.body(ifTrue); err != nil {
+		return err
+	}
+	toEnd := a.emit(OpJump, 0)
+
+	a.patch(toFalse, 0)
+	if err := a.body(ifFalse); err != nil {
+		return err
+	}
+	a.patch(toEnd, 0)
+	return nil
+}
+
+// logic compiles `and` and `or`, jump keeps the value which ends them
+func (a *assembler) logic(rest []Item, jump Opcode, empty Item) error {
+	if len(rest) == 0 {
+		_, err := a.emitConst(OpConst, empty)
+		return err
+	}
+
+	var jumps []int
+	for i, node := range rest {
+		if err := a.node(node); err != nil {
+			return err
+		}
+		if i < len(rest)-1 {
+			jumps = append(jumps, a.emit(jump, 0))
+		}
+	}
+
+	for _, at := range jumps {
+		a.patch(at, 0)
+	}
+	return nil
+}
+
+func (a *assembler) cond(rest []Item) error {
+	if len(rest)%2 != 0 {
+		return fmt.Errorf("cond expects an even number of forms")
+	}
+
+	var ends []int
+	for i := 0; i < len(rest); i += 2 {
+		if err := a.node(rest[i]); err != nil {
+			return err
+		}
+		next := a.emit(OpJumpFalse, 0)
+
+		if err := a.node(rest[i+1]); err != nil {
+			return err
+		}
+		ends = append(ends, a.emit(OpJump, 0))
+		a.patch(next, 0)
+	}
+
+	if _, err := a.emitConst(OpConst, Nil{}); err != nil {
+		return err
+	}
+	for _, at := range ends {
+		a.patch(at, 0)
+	}
+	return nil
+}
+
+func (a *assembler) caseForm(rest []Item) error {
+	if err := a.node(rest[0]); err != nil {
+		return err
+	}
+
+	var ends []int
+	clauses := rest[1:]
+	for i := 0; i+1 < len(clauses); i += 2 {
+		consts := List{Value: []Item{clauses[i]}}
+		if list, ok := clauses[i].(List); ok {
+			consts = list
+		}
+
+		next, err := a.emitConst(OpMatch, consts, 0)
+		if err != nil {
+			return err
+		}
+
+		if err := a.node(clauses[i+1]); err != nil {
+			return err
+		}
+		ends = append(ends, a.emit(OpJump, 0))
+		a.patch(next, 1)
+	}
+
+	if len(clauses)%2 == 1 {
+		a.emit(OpPop)
+		if err := a.node(clauses[len(clauses)-1]); err != nil {
+			return err
+		}
+	} else {
+		a.emit(OpNoMatch)
+	}
+
+	for _, at := range ends {
+		a.patch(at, 0)
+	}
+	return nil
+}
+
+// try compiles parts of `try` into chunks running in the current frame
+func (a *assembler) try(rest []Item) error {
+	t, err := parseTry(rest)
+	if err != nil {
+		return err
+	}
+
+	parts := []struct {
+		name   string
+		params Item
+		body   []Item
+		skip   bool
+	}{
+		{name: "try", body: t.body},
+		{name: "catch", body: t.catch[min(1, len(t.catch)):], skip: t.catch == nil},
+		{name: "finally", body: t.finally, skip: t.finally == nil},
+	}
+	if t.catch != nil {
+		parts[1].params = t.catch[0]
+	}
+
+	operands := make([]int, len(parts))
+	for i, part := range parts {
+		operands[i] = noChunk
+		if part.skip {
+			continue
+		}
+
+		chunk, err := assemble(part.name, part.params, part.body)
+		if err != nil {
+			return err
+		}
+		if operands[i], err = a.constant(chunk); err != nil {
+			return err
+		}
+	}
+
+	a.emit(OpTry, operands...)
+	return nil
+}
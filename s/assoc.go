@@ -0,0 +1,322 @@
+package s
+
+import (
+	"context"
+	"fmt"
+)
+
+// lookup returns value under key of a hash or index of a vector, other
+// items have no values
+func lookup(coll Item, key Item) (Item, bool) {
+	switch v := coll.(type) {
+	case Hash:
+		return v.Get(key)
+	case Vector:
+		if i, ok := key.(Integer); ok && i.Value >= 0 && i.Value < int64(len(v.Value)) {
+			return v.Value[i.Value], true
+		}
+	}
+	return nil, false
+}
+
+// assoc returns copy of hash or vector with key set to value, nil is
+// treated as an empty hash
+func assoc(coll Item, key Item, value Item) (Item, error) {
+	switch v := coll.(type) {
+	case Nil:
+		return Hash{Value: []KeyValue{{Key: key, Value: value}}}, nil
+	case Hash:
+		return hashAssoc(v, key, value), nil
+	case Vector:
+		i, ok := key.(Integer)
+		if !ok {
+			return nil, fmt.Errorf("vector index expected integer, got %s", typeName(key))
+		}
+		if i.Value < 0 || i.Value > int64(len(v.Value)) {
+			return nil, fmt.Errorf("index %d out of range", i.Value)
+		}
+
+		values := append([]Item{}, v.Value...)
+		if i.Value == int64(len(values)) {
+			return Vector{Value: append(values, value)}, nil
+		}
+		values[i.Value] = value
+		return Vector{Value: values}, nil
+	default:
+		return nil, fmt.Errorf("expected hash or vector, got %s", typeName(coll))
+	}
+}
+
+// hashAssoc returns copy of hash with key set to value
+func hashAssoc(hash Hash, key Item, value Item) Hash {
+	kvs := make([]KeyValue, len(hash.Value), len(hash.Value)+1)
+	copy(kvs, hash.Value)
+
+	for i, kv := range kvs {
+		if kv.Key.Equal(key).IsTrue() {
+			kvs[i].Value = value
+			return Hash{Value: kvs}
+		}
+	}
+	return Hash{Value: append(kvs, KeyValue{Key: key, Value: value})}
+}
+
+// updateIn replaces value under path of keys in coll with result of
+// fn, missing levels are created as hashes. Errors of builtin name are
+// prefixed with it, those of fn are returned as they are.
+func updateIn(name string, coll Item, path []Item, fn func(Item) (Item, error)) (Item, error) {
+	if len(path) == 0 {
+		return fn(coll)
+	}
+
+	old, ok := lookup(coll, path[0])
+	if !ok {
+		old = Nil{}
+	}
+	value, err := updateIn(name, old, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	coll, err = assoc(coll, path[0], value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return coll, nil
+}
+
+// callable returns function applying item: functions are called, a
+// keyword looks itself up in its argument and a hash looks up its
+// argument, both with an optional default
+func callable(item Item) (Func, bool) {
+	switch v := item.(type) {
+	case Func:
+		return v, true
+	case Keyword:
+		return Builtin(printKey(v), Signature{Params: []Type{TypeAny}, Optional: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+			return get(args[0], v, args[1:])
+		}), true
+	case Hash:
+		return Builtin("hash", Signature{Params: []Type{TypeAny}, Optional: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+			return get(v, args[0], args[1:])
+		}), true
+	}
+	return Func{}, false
+}
+
+// get looks up key in coll, returning the first of missing or nil
+func get(coll Item, key Item, missing []Item) (Item, error) {
+	if value, ok := lookup(coll, key); ok {
+		return value, nil
+	}
+	if len(missing) > 0 {
+		return missing[0], nil
+	}
+	return Nil{}, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// initAssoc sets up functions reading and updating hashes and vectors
+func (e *Env) initAssoc() {
+	e.DefineBuiltin("get", Signature{Params: []Type{TypeAny, TypeAny}, Optional: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		return get(args[0], args[1], args[2:])
+	})
+
+	e.DefineBuiltin("get-in", Signature{Params: []Type{TypeAny, TypeSeq}, Optional: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		path, err := realize(ctx, args[1].(Seq))
+		if err != nil {
+			return nil, err
+		}
+
+		coll := args[0]
+		for _, key := range path {
+			value, ok := lookup(coll, key)
+			if !ok {
+				return get(Nil{}, key, args[2:])
+			}
+			coll = value
+		}
+		return coll, nil
+	})
+
+	e.DefineBuiltin("assoc", Signature{Params: []Type{TypeAny, TypeAny, TypeAny}, Rest: TypeAny}, func(ctx context.Context, args []Item) (Item, error) {
+		if len(args)%2 == 0 {
+			return nil, fmt.Errorf("assoc: expected keys with values")
+		}
+
+		coll := args[0]
+		for i := 1; i < len(args); i += 2 {
+			var err error
+			if coll, err = assoc(coll, args[i], args[i+1]); err != nil {
+				return nil, fmt.Errorf("assoc: %w", err)
+			}
+		}
+		if hash, ok := coll.(Hash); ok {
+			return hash, checkSize(ctx, len(hash.Value))
+		}
+		return coll, checkSize(ctx, len(coll.(Vector).Value))
+	})
+
+	e.DefineBuiltin("assoc-in", Signature{Params: []Type{TypeAny, TypeSeq, TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		path, err := realize(ctx, args[1].(Seq))
+		if err != nil {
+			return nil, err
+		}
+
+		return updateIn("assoc-in", args[0], path, func(Item) (Item, error) {
+			return args[2], nil
+		})
+	})
+
+	e.DefineBuiltin("dissoc", Signature{Params: []Type{TypeAny}, Rest: TypeAny}, func(ctx context.Context, args []Item) (Item, error) {
+		switch coll := args[0].(type) {
+		case Nil:
+			return coll, nil
+		case Hash:
+			kvs := make([]KeyValue, 0, len(coll.Value))
+			for _, kv := range coll.Value {
+				removed := false
+				for _, key := range args[1:] {
+					if kv.Key.Equal(key).IsTrue() {
+						removed = true
+						break
+					}
+				}
+				if !removed {
+					kvs = append(kvs, kv)
+				}
+			}
+			return Hash{Value: kvs}, nil
+		default:
+			return nil, fmt.Errorf("dissoc: argument 1 expected hash, got %s", typeName(coll))
+		}
+	})
+
+	e.DefineBuiltin("update", Signature{Params: []Type{TypeAny, TypeAny, TypeFunc}, Rest: TypeAny}, func(ctx context.Context, args []Item) (Item, error) {
+		fn := args[2].(Func)
+		return updateIn("update", args[0], args[1:2], func(old Item) (Item, error) {
+			return apply(ctx, fn, append([]Item{old}, args[3:]...))
+		})
+	})
+
+	e.DefineBuiltin("update-in", Signature{Params: []Type{TypeAny, TypeSeq, TypeFunc}, Rest: TypeAny}, func(ctx context.Context, args []Item) (Item, error) {
+		path, err := realize(ctx, args[1].(Seq))
+		if err != nil {
+			return nil, err
+		}
+
+		fn := args[2].(Func)
+		return updateIn("update-in", args[0], path, func(old Item) (Item, error) {
+			return apply(ctx, fn, append([]Item{old}, args[3:]...))
+		})
+	})
+
+	e.DefineBuiltin("keys", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		hash, err := hashArg("keys", 1, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		keys := make([]Item, len(hash.Value))
+		for i, kv := range hash.Value {
+			keys[i] = kv.Key
+		}
+		return List{Value: keys}, nil
+	})
+
+	e.DefineBuiltin("vals", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		hash, err := hashArg("vals", 1, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]Item, len(hash.Value))
+		for i, kv := range hash.Value {
+			values[i] = kv.Value
+		}
+		return List{Value: values}, nil
+	})
+
+	e.DefineBuiltin("merge", Signature{Rest: TypeAny}, func(ctx context.Context, args []Item) (Item, error) {
+		return merge(ctx, "merge", nil, args)
+	})
+
+	e.DefineBuiltin("merge-with", Signature{Params: []Type{TypeFunc}, Rest: TypeAny}, func(ctx context.Context, args []Item) (Item, error) {
+		fn := args[0].(Func)
+		return merge(ctx, "merge-with", &fn, args[1:])
+	})
+
+	e.DefineBuiltin("select-keys", Signature{Params: []Type{TypeAny, TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		hash, err := hashArg("select-keys", 1, args[0])
+		if err != nil {
+			return nil, err
+		}
+		keys, err := realize(ctx, args[1].(Seq))
+		if err != nil {
+			return nil, err
+		}
+
+		selected := Hash{Value: []KeyValue{}}
+		for _, key := range keys {
+			if value, ok := hash.Get(key); ok {
+				selected = hashAssoc(selected, key, value)
+			}
+		}
+		return selected, nil
+	})
+
+	e.DefineBuiltin("contains?", Signature{Params: []Type{TypeAny, TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		if _, ok := lookup(args[0], args[1]); ok {
+			return True{}, nil
+		}
+		return False{}, nil
+	})
+}
+
+// hashArg returns argument n as a hash, nil is an empty hash
+func hashArg(name string, n int, arg Item) (Hash, error) {
+	switch v := arg.(type) {
+	case Nil:
+		return Hash{}, nil
+	case Hash:
+		return v, nil
+	default:
+		return Hash{}, fmt.Errorf("%s: argument %d expected hash, got %s", name, n, typeName(arg))
+	}
+}
+
+// merge merges hashes left to right, values of keys already present
+// are replaced or, when fn is given, combined with fn
+func merge(ctx context.Context, name string, fn *Func, hashes []Item) (Item, error) {
+	var merged Item = Nil{}
+	for i, arg := range hashes {
+		hash, err := hashArg(name, i+1, arg)
+		if err != nil {
+			return nil, err
+		}
+		if arg.IsNil() {
+			continue
+		}
+		if merged.IsNil() {
+			merged = hash
+			continue
+		}
+
+		into := merged.(Hash)
+		for _, kv := range hash.Value {
+			value := kv.Value
+			if old, ok := into.Get(kv.Key); ok && fn != nil {
+				if value, err = apply(ctx, *fn, []Item{old, value}); err != nil {
+					return nil, err
+				}
+			}
+			into = hashAssoc(into, kv.Key, value)
+		}
+		if err := checkSize(ctx, len(into.Value)); err != nil {
+			return nil, err
+		}
+		merged = into
+	}
+	return merged, nil
+}
@@ -0,0 +1,73 @@
+package s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssoc(t *testing.T) {
+	for name, backend := range backends {
+		in := NewInterpreter(Options{Backend: backend})
+
+		cases := []struct {
+			input  string
+			output string
+			err    string
+		}{
+			{input: "(get {:a 1} :a)", output: "1"},
+			{input: "(get {:a 1} :b)", output: "nil"},
+			{input: "(get {:a 1} :b 0)", output: "0"},
+			{input: "(get [1 2] 1)", output: "2"},
+			{input: "(get nil :a)", output: "nil"},
+			{input: "(get-in {:a {:b [1 2]}} [:a :b 1])", output: "2"},
+			{input: "(get-in {:a 1} [:a :b] :none)", output: ":none"},
+			{input: "(assoc {:a 1} :b 2 :a 3)", output: "{:a 3 :b 2}"},
+			{input: "(assoc nil :a 1)", output: "{:a 1}"},
+			{input: "(assoc [1 2] 0 :x 2 :y)", output: "[:x 2 :y]"},
+			{input: "(assoc [1] 5 :x)", err: "assoc: index 5 out of range"},
+			{input: "(assoc {} :a)", err: "assoc: expected at least 3 arguments, got 2"},
+			{input: "(assoc {} :a 1 :b)", err: "assoc: expected keys with values"},
+			{input: "(assoc 1 :a 1)", err: "assoc: expected hash or vector, got integer"},
+			{input: "(assoc-in {:a {:b 1}} [:a :c] 2)", output: "{:a {:b 1 :c 2}}"},
+			{input: "(assoc-in {} [:a :b] 1)", output: "{:a {:b 1}}"},
+			{input: "(assoc-in {:a 1} [:a :b] 1)", err: "assoc-in: expected hash or vector, got integer"},
+			{input: "(dissoc {:a 1 :b 2 :c 3} :a :c)", output: "{:b 2}"},
+			{input: "(dissoc [1] 0)", err: "dissoc: argument 1 expected hash, got vector"},
+			{input: "(update {:n 1} :n + 10)", output: "{:n 11}"},
+			{input: "(update {} :n (fn [n] (if n n 0)))", output: "{:n 0}"},
+			{input: "(update-in {:a {:n 1}} [:a :n] + 1)", output: "{:a {:n 2}}"},
+			{input: "(update {:n 1} :n (fn [n] (throw (ex-info \"no\" {}))))", err: "no"},
+			{input: "(keys {:a 1 :b 2})", output: "(:a :b)"},
+			{input: "(vals {:a 1 :b 2})", output: "(1 2)"},
+			{input: "(keys [1])", err: "keys: argument 1 expected hash, got vector"},
+			{input: "(merge {:a 1 :b 1} nil {:b 2 :c 3})", output: "{:a 1 :b 2 :c 3}"},
+			{input: "(merge)", output: "nil"},
+			{input: "(merge-with + {:a 1 :b 1} {:b 2})", output: "{:a 1 :b 3}"},
+			{input: "(merge {} 1)", err: "merge: argument 2 expected hash, got integer"},
+			{input: "(select-keys {:a 1 :b 2 :c 3} [:a :c :d])", output: "{:a 1 :c 3}"},
+			{input: "(contains? {:a nil} :a)", output: "true"},
+			{input: "(contains? {:a 1} :b)", output: "false"},
+			{input: "(contains? [1 2] 1)", output: "true"},
+			{input: "(contains? [1 2] 2)", output: "false"},
+
+			{input: "(:name {:name \"Ann\"})", output: `"Ann"`},
+			{input: "(:age {:name \"Ann\"} 30)", output: "30"},
+			{input: "(:name nil)", output: "nil"},
+			{input: "({:a 1} :a)", output: "1"},
+			{input: "({:a 1} :b 2)", output: "2"},
+			{input: "(:a)", err: ":a: expected 1 to 2 arguments, got 0"},
+			{input: "(map (fn [user] (:name user)) (list {:name 1} {:name 2}))", output: "(1 2)"},
+		}
+
+		for _, c := range cases {
+			res, err := in.Rep(c.input)
+			if c.err != "" {
+				assert.EqualError(t, err, c.err, "%s: %s", name, c.input)
+				continue
+			}
+			assert.NoError(t, err, "%s: %s", name, c.input)
+			assert.Equal(t, c.output, res, "%s: %s", name, c.input)
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package s
 
+import (
+	"context"
+	"reflect"
+	"unique"
+)
+
 // Item is main AST interface
 type Item interface {
 	Equal(Item) Item
@@ -16,6 +22,12 @@ type Item interface {
 	IsFunc() bool
 }
 
+// Truthy reports whether given Item counts as true in conditions.
+// Only `false` and `nil` are falsy, everything else is truthy.
+func Truthy(i Item) bool {
+	return !(i.IsFalse() || i.IsNil())
+}
+
 type DefaultItem struct{}
 
 func (self DefaultItem) IsTrue() bool {
@@ -149,6 +161,27 @@ func (self Integer) Equal(i Item) Item {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// Float is a 64-bit floating point number
+type Float struct {
+	DefaultItem
+	Value float64
+}
+
+func (self Float) Equal(i Item) Item {
+	switch v := i.(type) {
+	case Float:
+		if self.Value != v.Value {
+			return False{}
+		}
+		return True{}
+
+	default:
+		return False{}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
 type String struct {
 	DefaultItem
 	Value string
@@ -173,9 +206,30 @@ func (self String) Equal(i Item) Item {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// Symbols and keywords keep a handle of their name interned in a global
+// table, which keeps the entry alive. Names made with NewSymbol and
+// NewKeyword are compared by handle.
+
+// sameName compares names by handle, names of literals built without a
+// constructor have no handle and are compared by value
+func sameName(a, b unique.Handle[string], x, y string) bool {
+	var none unique.Handle[string]
+	if a == none || b == none {
+		return x == y
+	}
+	return a == b
+}
+
 type Symbol struct {
 	DefaultItem
 	Value string
+	name  unique.Handle[string]
+}
+
+// NewSymbol returns symbol with interned name
+func NewSymbol(name string) Symbol {
+	h := unique.Make(name)
+	return Symbol{Value: h.Value(), name: h}
 }
 
 func (self Symbol) IsSymbol() bool {
@@ -185,7 +239,7 @@ func (self Symbol) IsSymbol() bool {
 func (self Symbol) Equal(i Item) Item {
 	switch v := i.(type) {
 	case Symbol:
-		if self.Value != v.Value {
+		if !sameName(self.name, v.name, self.Value, v.Value) {
 			return False{}
 		}
 		return True{}
@@ -200,6 +254,13 @@ func (self Symbol) Equal(i Item) Item {
 type Keyword struct {
 	DefaultItem
 	Value string
+	name  unique.Handle[string]
+}
+
+// NewKeyword returns keyword with interned name
+func NewKeyword(name string) Keyword {
+	h := unique.Make(name)
+	return Keyword{Value: h.Value(), name: h}
 }
 
 func (self Keyword) IsKeyword() bool {
@@ -209,7 +270,7 @@ func (self Keyword) IsKeyword() bool {
 func (self Keyword) Equal(i Item) Item {
 	switch v := i.(type) {
 	case Keyword:
-		if self.Value != v.Value {
+		if !sameName(self.name, v.name, self.Value, v.Value) {
 			return False{}
 		}
 		return True{}
@@ -224,6 +285,8 @@ func (self Keyword) Equal(i Item) Item {
 type List struct {
 	DefaultItem
 	Value []Item
+	// pos is set on lists read from source, see PositionOf
+	pos *Position
 }
 
 func (self List) IsList() bool {
@@ -315,6 +378,26 @@ func (self Hash) Add(kv KeyValue) Hash {
 	return self
 }
 
+// Get returns value stored under given key
+func (self Hash) Get(key Item) (Item, bool) {
+	// Keywords, the usual keys, are matched without dispatching
+	if k, ok := key.(Keyword); ok {
+		for _, kv := range self.Value {
+			if v, ok := kv.Key.(Keyword); ok && sameName(v.name, k.name, v.Value, k.Value) {
+				return kv.Value, true
+			}
+		}
+		return nil, false
+	}
+
+	for _, kv := range self.Value {
+		if kv.Key.Equal(key).IsTrue() {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 type Vector struct {
@@ -353,8 +436,29 @@ func (self Vector) Add(i Item) Vector {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// GoValue is an opaque Go value handed to scripts by the host, its
+// members are reachable through `(. value Member args...)` once allowed
+// by Interpreter.Allow
+type GoValue struct {
+	DefaultItem
+	Value any
+}
+
+func (self GoValue) Equal(i Item) Item {
+	v, ok := i.(GoValue)
+	if !ok || reflect.TypeOf(self.Value) != reflect.TypeOf(v.Value) {
+		return False{}
+	}
+	if !reflect.ValueOf(self.Value).Comparable() || self.Value != v.Value {
+		return False{}
+	}
+	return True{}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
 // ItemFunc is a type definition of environment function
-type ItemFunc func([]Item) (Item, error)
+type ItemFunc func(context.Context, []Item) (Item, error)
 
 type Func struct {
 	DefaultItem
@@ -368,3 +472,66 @@ func (self Func) IsFunc() bool {
 func (self Func) Equal(i Item) Item {
 	return False{}
 }
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ExInfo is an error value with a message and attached data, created by
+// `ex-info` or converted from a Go error
+type ExInfo struct {
+	DefaultItem
+	Message string
+	Data    Item
+}
+
+func (self ExInfo) Equal(i Item) Item {
+	switch v := i.(type) {
+	case ExInfo:
+		if self.Message != v.Message {
+			return False{}
+		}
+		return self.Data.Equal(v.Data)
+
+	default:
+		return False{}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Local is a local variable resolved by the analyzer, Depth is the number
+// of function frames to go up from the current one and Index is its slot
+type Local struct {
+	DefaultItem
+	Name  string
+	Depth int
+	Index int
+}
+
+func (self Local) Equal(i Item) Item {
+	switch v := i.(type) {
+	case Local:
+		if self.Name != v.Name || self.Depth != v.Depth || self.Index != v.Index {
+			return False{}
+		}
+		return True{}
+
+	default:
+		return False{}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Lambda is an analyzed `fn` form, evaluating it creates a Func
+type Lambda struct {
+	DefaultItem
+	Params   Vector
+	Body     []Item
+	Slots    int
+	Required int
+	Variadic bool
+}
+
+func (self Lambda) Equal(i Item) Item {
+	return False{}
+}
@@ -0,0 +1,249 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Future is a result of a function running on its own goroutine
+type Future struct {
+	DefaultItem
+	done  chan struct{}
+	value Item
+	err   error
+}
+
+// Spawn calls fn with args on a new goroutine. It runs under ctx, so it
+// stops once the evaluation which started it is cancelled.
+func Spawn(ctx context.Context, fn Func, args []Item) *Future {
+	f := &Future{done: make(chan struct{})}
+	ctx = forkBudget(ctx)
+
+	go func() {
+		defer close(f.done)
+		defer func() {
+			if r := recover(); r != nil {
+				f.err = fmt.Errorf("panic in goroutine: %v", r)
+			}
+		}()
+		f.value, f.err = apply(ctx, fn, args)
+	}()
+
+	return f
+}
+
+func (self *Future) Equal(i Item) Item {
+	if v, ok := i.(*Future); ok && v == self {
+		return True{}
+	}
+	return False{}
+}
+
+// Wait returns result of the future once it is done, or an error once
+// ctx is done
+func (self *Future) Wait(ctx context.Context) (Item, error) {
+	select {
+	case <-self.done:
+		return self.value, self.err
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Channel passes items between goroutines. Closed channel refuses new
+// items, but buffered ones can still be taken.
+type Channel struct {
+	DefaultItem
+	ch     chan Item
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewChannel returns channel buffering up to size items
+func NewChannel(size int) *Channel {
+	return &Channel{ch: make(chan Item, size), closed: make(chan struct{})}
+}
+
+func (self *Channel) Equal(i Item) Item {
+	if v, ok := i.(*Channel); ok && v == self {
+		return True{}
+	}
+	return False{}
+}
+
+// Close closes the channel, closing it again does nothing
+func (self *Channel) Close() {
+	self.once.Do(func() { close(self.closed) })
+}
+
+// Put sends item, it returns false when the channel is closed
+func (self *Channel) Put(ctx context.Context, item Item) (bool, error) {
+	select {
+	case <-self.closed:
+		return false, nil
+	default:
+	}
+
+	select {
+	case self.ch <- item:
+		return true, nil
+	case <-self.closed:
+		return false, nil
+	case <-ctx.Done():
+		return false, context.Cause(ctx)
+	}
+}
+
+// Take receives item, it returns nil once the channel is closed and empty
+func (self *Channel) Take(ctx context.Context) (Item, error) {
+	select {
+	case item := <-self.ch:
+		return item, nil
+	case <-self.closed:
+		return self.drain(), nil
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// drain returns item left in a closed channel or nil
+func (self *Channel) drain() Item {
+	select {
+	case item := <-self.ch:
+		return item
+	default:
+		return Nil{}
+	}
+}
+
+// alts performs the first ready of given operations. Channel items are
+// takes, pairs of a channel and an item are puts. It returns result
+// of the operation, an item or a boolean, and its channel.
+func alts(ctx context.Context, ops []Item) (Item, Item, error) {
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}}
+	var channels []*Channel
+	var takes []bool
+
+	for i, op := range ops {
+		var item Item
+		ch, take := op.(*Channel)
+		if !take {
+			put, err := seqItems(op)
+			ok := err == nil && len(put) == 2
+			if ok {
+				ch, ok = put[0].(*Channel)
+				item = put[1]
+			}
+			if !ok {
+				return nil, nil, fmt.Errorf("alts!: operation %d expected channel or (channel item), got %s", i+1, typeName(op))
+			}
+			if item.IsNil() {
+				return nil, nil, fmt.Errorf("alts!: cannot put nil on a channel")
+			}
+		}
+
+		if take {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.ch)})
+		} else {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch.ch), Send: reflect.ValueOf(&item).Elem()})
+		}
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.closed)})
+		channels = append(channels, ch)
+		takes = append(takes, take)
+	}
+
+	chosen, received, _ := reflect.Select(cases)
+	if chosen == 0 {
+		return nil, nil, context.Cause(ctx)
+	}
+
+	op := (chosen - 1) / 2
+	ch, take := channels[op], takes[op]
+	closed := (chosen-1)%2 == 1
+
+	switch {
+	case take && closed:
+		return ch.drain(), ch, nil
+	case take:
+		return received.Interface().(Item), ch, nil
+	case closed:
+		return False{}, ch, nil
+	default:
+		return True{}, ch, nil
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// initAsync sets up goroutines and channels
+func (e *Env) initAsync() {
+	e.DefineBuiltin("spawn", Signature{Params: []Type{TypeFunc}, Rest: TypeAny}, func(ctx context.Context, args []Item) (Item, error) {
+		return Spawn(ctx, args[0].(Func), args[1:]), nil
+	})
+
+	e.DefineBuiltin("chan", Signature{Optional: []Type{TypeInteger}}, func(ctx context.Context, args []Item) (Item, error) {
+		size := 0
+		if len(args) > 0 {
+			size = int(args[0].(Integer).Value)
+		}
+
+		if size < 0 {
+			return nil, fmt.Errorf("chan: negative buffer size %d", size)
+		}
+		if err := checkSize(ctx, size); err != nil {
+			return nil, err
+		}
+		return NewChannel(size), nil
+	})
+
+	e.DefineBuiltin("timeout", Signature{Params: []Type{TypeInteger}}, func(ctx context.Context, args []Item) (Item, error) {
+		ch := NewChannel(0)
+		time.AfterFunc(time.Duration(args[0].(Integer).Value)*time.Millisecond, ch.Close)
+		return ch, nil
+	})
+
+	e.DefineBuiltin(">!", Signature{Params: []Type{TypeChannel, TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		if args[1].IsNil() {
+			return nil, fmt.Errorf(">!: cannot put nil on a channel")
+		}
+
+		ok, err := args[0].(*Channel).Put(ctx, args[1])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return True{}, nil
+		}
+		return False{}, nil
+	})
+
+	e.DefineBuiltin("<!", Signature{Params: []Type{TypeChannel}}, func(ctx context.Context, args []Item) (Item, error) {
+		return args[0].(*Channel).Take(ctx)
+	})
+
+	e.DefineBuiltin("close!", Signature{Params: []Type{TypeChannel}}, func(ctx context.Context, args []Item) (Item, error) {
+		args[0].(*Channel).Close()
+		return Nil{}, nil
+	})
+
+	e.DefineBuiltin("alts!", Signature{Params: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		ops, err := realize(ctx, args[0].(Seq))
+		if err != nil {
+			return nil, err
+		}
+		if len(ops) == 0 {
+			return nil, fmt.Errorf("alts!: expected at least one operation")
+		}
+
+		value, ch, err := alts(ctx, ops)
+		if err != nil {
+			return nil, err
+		}
+		return Vector{Value: []Item{value, ch}}, nil
+	})
+}
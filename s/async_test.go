@@ -0,0 +1,90 @@
+package s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsync(t *testing.T) {
+	for name, backend := range backends {
+		in := NewInterpreter(Options{Backend: backend})
+
+		cases := []struct {
+			input  string
+			output string
+			err    string
+		}{
+			{input: "(set sq (fn [x] (* x x)))", output: "function"},
+			{input: "@(spawn sq 7)", output: "49"},
+			{input: "(let [n 3] @(go (sq n)))", output: "9"},
+			{input: "(deref (go (sleep 200) :late) 10 :timeout)", output: ":timeout"},
+			{input: "(deref (go :fast) 1000 :timeout)", output: ":fast"},
+			{input: "@(go (throw :boom))", err: "uncaught exception: :boom"},
+
+			{input: "(set c (chan 2))", output: "#<Channel>"},
+			{input: "(>! c 1)", output: "true"},
+			{input: "(>! c 2)", output: "true"},
+			{input: "(close! c)", output: "nil"},
+			{input: "(>! c 3)", output: "false"},
+			{input: "(list (<! c) (<! c) (<! c))", output: "(1 2 nil)"},
+			{input: "(>! c nil)", err: ">!: cannot put nil on a channel"},
+
+			// Fan out and collect over an unbuffered channel
+			{input: "(set out (chan))", output: "#<Channel>"},
+			{input: "(do (go (>! out (sq 2))) (go (>! out (sq 3))) (+ (<! out) (<! out)))", output: "13"},
+
+			{input: "(let [a (chan) b (chan 1)] (>! b :b) (alts! (list a b)))", output: "[:b #<Channel>]"},
+			{input: "(let [a (chan 1) [ok ch] (alts! (list (list a :put)))] (list ok (= ch a) (<! a)))", output: "(true true :put)"},
+			{input: "(let [[v ch] (alts! (list (chan) (timeout 10)))] v)", output: "nil"},
+			{input: "(alts! (list 1))", err: "alts!: operation 1 expected channel or (channel item), got integer"},
+		}
+
+		for _, c := range cases {
+			res, err := in.Rep(c.input)
+			if c.err != "" {
+				assert.EqualError(t, err, c.err, "%s: %s", name, c.input)
+				continue
+			}
+			assert.NoError(t, err, "%s: %s", name, c.input)
+			assert.Equal(t, c.output, res, "%s: %s", name, c.input)
+		}
+	}
+}
+
+func TestAsync_Cancel(t *testing.T) {
+	in := NewInterpreter(Options{})
+
+	// Blocked take stops with the evaluation
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := in.EvalStringContext(ctx, "(<! (chan))")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Goroutines share the time limit of the evaluation which started them
+	in = NewInterpreter(Options{Limits: Limits{Timeout: 20 * time.Millisecond}})
+	_, err = in.EvalString("@(go (<! (chan)))")
+	var limit *LimitExceeded
+	assert.True(t, errors.As(err, &limit))
+
+	// Depth is counted per goroutine, steps for the whole evaluation
+	in = NewInterpreter(Options{Limits: Limits{MaxDepth: 20, MaxSteps: 1000}})
+	_, err = in.EvalString("(set f (fn [n] (if (= n 0) 0 (f (- n 1)))))")
+	assert.NoError(t, err)
+	res, err := in.Rep("(list @(go (f 15)) @(go (f 15)) (f 15))")
+	assert.NoError(t, err)
+	assert.Equal(t, "(0 0 0)", res)
+
+	_, err = in.EvalString("(set loop (fn [] (loop))) @(go (loop))")
+	if assert.True(t, errors.As(err, &limit)) {
+		assert.Equal(t, "depth", limit.Limit)
+	}
+	in = NewInterpreter(Options{Limits: Limits{MaxSteps: 200}})
+	_, err = in.EvalString("(set g (fn [n] (if (= n 0) 0 (g (- n 1))))) (list @(go (g 15)) @(go (g 15)) (g 15) (g 15) (g 15))")
+	if assert.True(t, errors.As(err, &limit)) {
+		assert.Equal(t, "steps", limit.Limit)
+	}
+}
@@ -0,0 +1,137 @@
+package s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Atom is a mutable reference to a value, it is safe for concurrent use
+type Atom struct {
+	DefaultItem
+	state atomic.Pointer[atomState]
+}
+
+// atomState is never modified, so the atom can swap it as a whole
+type atomState struct {
+	value Item
+}
+
+// NewAtom returns atom holding value
+func NewAtom(value Item) *Atom {
+	a := &Atom{}
+	a.Reset(value)
+	return a
+}
+
+func (self *Atom) Equal(i Item) Item {
+	if v, ok := i.(*Atom); ok && v == self {
+		return True{}
+	}
+	return False{}
+}
+
+// Deref returns current value
+func (self *Atom) Deref() Item {
+	return self.state.Load().value
+}
+
+// Reset sets value regardless of the current one
+func (self *Atom) Reset(value Item) {
+	self.state.Store(&atomState{value: value})
+}
+
+// Swap sets value to fn applied to the current one. When another
+// goroutine changes the atom meanwhile, fn is called again with the new
+// value, so it must be free of side effects.
+func (self *Atom) Swap(fn func(Item) (Item, error)) (Item, error) {
+	for {
+		old := self.state.Load()
+		value, err := fn(old.value)
+		if err != nil {
+			return nil, err
+		}
+
+		if self.state.CompareAndSwap(old, &atomState{value: value}) {
+			return value, nil
+		}
+	}
+}
+
+// CompareAndSet sets value only if the current one equals old
+func (self *Atom) CompareAndSet(old Item, value Item) bool {
+	current := self.state.Load()
+	if !current.value.Equal(old).IsTrue() {
+		return false
+	}
+	return self.state.CompareAndSwap(current, &atomState{value: value})
+}
+
+// initAtoms sets up atoms, they are part of core
+func (e *Env) initAtoms() {
+	e.DefineBuiltin("atom", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		return NewAtom(args[0]), nil
+	})
+
+	e.DefineBuiltin("atom?", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		if _, ok := args[0].(*Atom); ok {
+			return True{}, nil
+		}
+		return False{}, nil
+	})
+
+	deref := Signature{Params: []Type{TypeAny}, Optional: []Type{TypeInteger, TypeAny}}
+	e.DefineBuiltin("deref", deref, func(ctx context.Context, args []Item) (Item, error) {
+		switch v := args[0].(type) {
+		case *Atom:
+			if len(args) > 1 {
+				return nil, fmt.Errorf("deref: timeout is only supported by futures")
+			}
+			return v.Deref(), nil
+
+		case *Future:
+			if len(args) == 1 {
+				return v.Wait(ctx)
+			}
+
+			// (deref future ms timeout-value)
+			wait, cancel := context.WithTimeout(ctx, time.Duration(args[1].(Integer).Value)*time.Millisecond)
+			defer cancel()
+
+			value, err := v.Wait(wait)
+			if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				if len(args) > 2 {
+					return args[2], nil
+				}
+				return Nil{}, nil
+			}
+			return value, err
+
+		default:
+			return nil, fmt.Errorf("deref: argument 1 expected atom or future, got %s", typeName(args[0]))
+		}
+	})
+
+	e.DefineBuiltin("reset!", Signature{Params: []Type{TypeAtom, TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		args[0].(*Atom).Reset(args[1])
+		return args[1], nil
+	})
+
+	swap := Signature{Params: []Type{TypeAtom, TypeFunc}, Rest: TypeAny}
+	e.DefineBuiltin("swap!", swap, func(ctx context.Context, args []Item) (Item, error) {
+		fn := args[1].(Func)
+		return args[0].(*Atom).Swap(func(value Item) (Item, error) {
+			return apply(ctx, fn, append([]Item{value}, args[2:]...))
+		})
+	})
+
+	cas := Signature{Params: []Type{TypeAtom, TypeAny, TypeAny}}
+	e.DefineBuiltin("compare-and-set!", cas, func(ctx context.Context, args []Item) (Item, error) {
+		if args[0].(*Atom).CompareAndSet(args[1], args[2]) {
+			return True{}, nil
+		}
+		return False{}, nil
+	})
+}
@@ -0,0 +1,69 @@
+package s
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtom(t *testing.T) {
+	for name, backend := range backends {
+		in := NewInterpreter(Options{Backend: backend})
+
+		cases := []struct {
+			input  string
+			output string
+			err    string
+		}{
+			{input: "(set a (atom 1))", output: "#<Atom 1>"},
+			{input: "@a", output: "1"},
+			{input: "(deref a)", output: "1"},
+			{input: "(swap! a + 10 5)", output: "16"},
+			{input: "(reset! a (list 1 2))", output: "(1 2)"},
+			{input: "(compare-and-set! a (list 1 2) :new)", output: "true"},
+			{input: "(compare-and-set! a :old :newer)", output: "false"},
+			{input: "@a", output: ":new"},
+			{input: "(let [b a] (= a b))", output: "true"},
+			{input: "(= a (atom :new))", output: "false"},
+			{input: "(atom? a)", output: "true"},
+			{input: "(set cache (atom {}))", output: "#<Atom {}>"},
+			{input: "(swap! a (fn [v] (throw :no)))", err: "uncaught exception: :no"},
+			{input: "@1", err: "deref: argument 1 expected atom or future, got integer"},
+			{input: "(swap! a 1)", err: "swap!: argument 2 expected function, got integer"},
+		}
+
+		for _, c := range cases {
+			res, err := in.Rep(c.input)
+			if c.err != "" {
+				assert.EqualError(t, err, c.err, "%s: %s", name, c.input)
+				continue
+			}
+			assert.NoError(t, err, "%s: %s", name, c.input)
+			assert.Equal(t, c.output, res, "%s: %s", name, c.input)
+		}
+	}
+}
+
+func TestAtom_Concurrent(t *testing.T) {
+	in := NewInterpreter(Options{})
+	_, err := in.EvalString("(set counter (atom 0)) (set inc (fn [n] (+ n 1)))")
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, err := in.EvalString("(swap! counter inc)")
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	res, err := in.Rep("@counter")
+	assert.NoError(t, err)
+	assert.Equal(t, "800", res)
+}
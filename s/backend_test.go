@@ -0,0 +1,115 @@
+package s
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var backends = map[string]Backend{
+	"tree":    BackendTree,
+	"closure": BackendClosure,
+	"vm":      BackendVM,
+}
+
+// fromMap turns unordered cases into a sorted scenario
+func fromMap(cases map[string]string) []repCase {
+	out := make([]repCase, 0, len(cases))
+	for input, output := range cases {
+		out = append(out, repCase{input: input, output: output})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].input < out[j].input })
+	return out
+}
+
+// backendSuites holds every rep_test.go scenario, each of them runs in a
+// fresh interpreter
+var backendSuites = map[string][]repCase{
+	"rep":          fromMap(repTestcases),
+	"set":          setCases,
+	"let":          letCases,
+	"let-vector":   letVectorCases,
+	"outer":        outerCases,
+	"lists":        fromMap(listCases),
+	"if":           fromMap(ifCases),
+	"cond":         fromMap(condCases),
+	"fn":           fromMap(fnCases),
+	"closures":     closureCases,
+	"not":          notCases,
+	"conditionals": conditionalCases,
+	"backend": {
+		{input: "(set fib (fn [n] (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2))))))", output: "function"},
+		{input: "(fib 15)", output: "610"},
+		{input: "((fn [a & more] (list a more)) 1 2 3)", output: "(1 (2 3))"},
+		{input: "((fn [{:keys [a b] :or {b 5}}] (+ a b)) {:a 1})", output: "6"},
+		{input: "(let [x 1] ((fn [] (let [y 2] ((fn [] (+ x y)))))))", output: "3"},
+		{input: "(try (throw 1) (catch e (+ e 1)) (finally 5))", output: "2"},
+		{input: "(try (throw (ex-info \"boom\" {})) (catch e (ex-message e)))", output: `"boom"`},
+		{input: "((fn [a] a))", err: "wrong number of args (0) passed to fn"},
+		{input: "(1 2)", err: "Unexpected type of {{} 1}"},
+		{input: "(let [a 1] b)", err: "b is undefined"},
+		{input: "(if)", err: "if expects a condition and a branch"},
+		{input: "(if 1)", err: "if expects a condition and a branch"},
+		{input: "(if false 1 2 3)", err: "if expects at most two branches, got 3"},
+		{input: "(let [f (fn [] (throw :oops))] (f))", err: "uncaught exception: :oops"},
+	},
+}
+
+func TestBackends(t *testing.T) {
+	for suite, cases := range backendSuites {
+		results := map[string][]string{}
+
+		for name, backend := range backends {
+			in := NewInterpreter(Options{Backend: backend})
+
+			// Record outputs and errors to compare backends with each other
+			rep := func(input string) (string, error) {
+				res, err := in.Rep(input)
+				if err != nil {
+					results[name] = append(results[name], "error: "+err.Error())
+				} else {
+					results[name] = append(results[name], res)
+				}
+				return res, err
+			}
+
+			t.Run(suite+"/"+name, func(t *testing.T) {
+				runRepCases(t, rep, cases)
+			})
+		}
+
+		for name := range backends {
+			assert.Equal(t, results["tree"], results[name], "%s on %s", suite, name)
+		}
+	}
+}
+
+func TestBackends_Stack(t *testing.T) {
+	code := "(set f (fn [] (throw 1)))\n(set g (fn [] (f)))\n(g)"
+
+	traces := map[string]string{}
+	for name, backend := range backends {
+		in := NewInterpreter(Options{Backend: backend})
+		_, err := in.EvalString(code)
+
+		evalErr, ok := err.(*EvalError)
+		if assert.True(t, ok, name) {
+			traces[name] = evalErr.Trace()
+		}
+	}
+
+	assert.NotEmpty(t, traces["tree"])
+	for name := range backends {
+		assert.Equal(t, traces["tree"], traces[name], name)
+	}
+}
+
+func BenchmarkBackends_Fib(b *testing.B) {
+	for name, backend := range backends {
+		b.Run(name, func(b *testing.B) {
+			benchmarkEval(b, Options{Backend: backend},
+				"(set fib (fn [n] (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2))))))", "(fib 15)")
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Type is a predicate builtins check their arguments with
+type Type struct {
+	// Name is shown in errors, e.g. "integer"
+	Name  string
+	Check func(Item) bool
+	// Convert, if set, turns a checked argument into the item builtins
+	// get, e.g. a callable keyword into a function. It returns false for
+	// arguments passed as they are.
+	Convert func(Item) (Item, bool)
+}
+
+// Types of builtin arguments
+var (
+	TypeAny     = Type{Name: "any", Check: func(Item) bool { return true }}
+	TypeInteger = Type{Name: "integer", Check: Item.IsInteger}
+	TypeNumber  = Type{Name: "number", Check: func(item Item) bool {
+		_, ok := item.(Float)
+		return ok || item.IsInteger()
+	}}
+	TypeString  = Type{Name: "string", Check: Item.IsString}
+	TypeSymbol  = Type{Name: "symbol", Check: Item.IsSymbol}
+	TypeKeyword = Type{Name: "keyword", Check: Item.IsKeyword}
+	TypeList    = Type{Name: "list", Check: Item.IsList}
+	TypeVector  = Type{Name: "vector", Check: Item.IsVector}
+	TypeHash    = Type{Name: "hash", Check: Item.IsHash}
+	TypeFunc    = Type{Name: "function", Check: func(item Item) bool {
+		_, ok := callable(item)
+		return ok
+	}, Convert: func(item Item) (Item, bool) {
+		if _, ok := item.(Func); ok {
+			return item, false
+		}
+		fn, _ := callable(item)
+		return fn, true
+	}}
+	TypeGoValue = Type{Name: "GoValue", Check: func(item Item) bool {
+		_, ok := item.(GoValue)
+		return ok
+	}}
+	TypeAtom = Type{Name: "atom", Check: func(item Item) bool {
+		_, ok := item.(*Atom)
+		return ok
+	}}
+	TypeChannel = Type{Name: "channel", Check: func(item Item) bool {
+		_, ok := item.(*Channel)
+		return ok
+	}}
+	TypeSeq = Type{Name: "sequence", Check: func(item Item) bool {
+		_, ok := item.(Seq)
+		return ok
+	}}
+)
+
+// Signature declares arguments a builtin accepts: required Params, then
+// Optional ones and, when Rest has a Check, any number of trailing ones
+type Signature struct {
+	Params   []Type
+	Optional []Type
+	Rest     Type
+}
+
+// check validates args of builtin name against the signature
+func (sig Signature) check(name string, args []Item) error {
+	min := len(sig.Params)
+	max := min + len(sig.Optional)
+	variadic := sig.Rest.Check != nil
+
+	if len(args) < min || (!variadic && len(args) > max) {
+		return fmt.Errorf("%s: expected %s, got %d", name, sig.arity(), len(args))
+	}
+
+	for i, arg := range args {
+		typ := sig.param(i)
+		if !typ.Check(arg) {
+			return fmt.Errorf("%s: argument %d expected %s, got %s", name, i+1, typ.Name, typeName(arg))
+		}
+	}
+	return nil
+}
+
+// param returns type of argument i
+func (sig Signature) param(i int) Type {
+	switch {
+	case i < len(sig.Params):
+		return sig.Params[i]
+	case i < len(sig.Params)+len(sig.Optional):
+		return sig.Optional[i-len(sig.Params)]
+	default:
+		return sig.Rest
+	}
+}
+
+// convert returns checked args as builtins get them, args are copied
+// before any of them is converted
+func (sig Signature) convert(args []Item) []Item {
+	converted := args
+	for i, arg := range args {
+		typ := sig.param(i)
+		if typ.Convert == nil {
+			continue
+		}
+		item, ok := typ.Convert(arg)
+		if !ok {
+			continue
+		}
+		if &converted[0] == &args[0] {
+			converted = append([]Item(nil), args...)
+		}
+		converted[i] = item
+	}
+	return converted
+}
+
+// arity describes number of accepted arguments
+func (sig Signature) arity() string {
+	min := len(sig.Params)
+	max := min + len(sig.Optional)
+
+	plural := func(n int) string {
+		if n == 1 {
+			return "argument"
+		}
+		return "arguments"
+	}
+
+	switch {
+	case sig.Rest.Check != nil:
+		return fmt.Sprintf("at least %d %s", min, plural(min))
+	case min == max:
+		return fmt.Sprintf("%d %s", min, plural(min))
+	default:
+		return fmt.Sprintf("%d to %d arguments", min, max)
+	}
+}
+
+// Builtin returns function which checks its arguments against sig
+// before calling fn, so fn can assert their types safely
+func Builtin(name string, sig Signature, fn ItemFunc) Func {
+	return Func{Value: func(ctx context.Context, args []Item) (Item, error) {
+		if err := sig.check(name, args); err != nil {
+			return nil, err
+		}
+		return fn(ctx, sig.convert(args))
+	}}
+}
+
+// DefineBuiltin defines a checked builtin, see Builtin
+func (e *Env) DefineBuiltin(name string, sig Signature, fn ItemFunc) Item {
+	return e.Define(name, Builtin(name, sig, fn))
+}
+
+// apply calls fn from a builtin, the call is accounted as a step
+func apply(ctx context.Context, fn Func, args []Item) (Item, error) {
+	if err := step(ctx); err != nil {
+		return nil, err
+	}
+	return fn.Value(ctx, args)
+}
+
+// typeName returns name of item type as shown to users
+func typeName(item Item) string {
+	switch item.(type) {
+	case nil, Nil:
+		return "nil"
+	case True, False:
+		return "boolean"
+	case Integer:
+		return "integer"
+	case Float:
+		return "float"
+	case String:
+		return "string"
+	case Symbol:
+		return "symbol"
+	case Keyword:
+		return "keyword"
+	case List:
+		return "list"
+	case Vector:
+		return "vector"
+	case Hash:
+		return "hash"
+	case Func, *Chunk:
+		return "function"
+	case ExInfo:
+		return "ex-info"
+	case GoValue:
+		return "GoValue"
+	case *Atom:
+		return "atom"
+	case *Future:
+		return "future"
+	case *Channel:
+		return "channel"
+	case Cons:
+		return "seq"
+	case *LazySeq:
+		return "lazy-seq"
+	case Reduced:
+		return "reduced"
+	default:
+		return strings.ToLower(strings.TrimPrefix(fmt.Sprintf("%T", item), "s."))
+	}
+}
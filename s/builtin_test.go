@@ -0,0 +1,102 @@
+package s
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltin_Errors(t *testing.T) {
+	in := NewInterpreter(Options{})
+
+	cases := map[string]string{
+		`(+ 1 "a")`:           "+: argument 2 expected number, got string",
+		`(-)`:                 "-: expected at least 1 argument, got 0",
+		`(< 1)`:               "<: expected 2 arguments, got 1",
+		`(> 1 :a)`:            ">: argument 2 expected number, got keyword",
+		`(/ 1 0)`:             "/: division by zero",
+		`(= 1)`:               "=: expected 2 arguments, got 1",
+		`(empty? 1)`:          "empty?: argument 1 expected sequence, got integer",
+		`(not)`:               "not: expected 1 argument, got 0",
+		`(ex-info :a)`:        "ex-info: argument 1 expected string, got keyword",
+		`(ex-info "a" {} 1)`:  "ex-info: expected 1 to 2 arguments, got 3",
+		`(read-string nil)`:   "read-string: argument 1 expected string, got nil",
+		`(subs "abc" 1 "2")`:  "subs: argument 3 expected integer, got string",
+		`(subs "abc" 2 1)`:    "subs: bounds [2 1] out of range for length 3",
+		`(join "," (list 1))`: "join: item 1 expected string, got integer",
+		`(sleep (list))`:      "sleep: argument 1 expected integer, got list",
+		`(now 1)`:             "now: expected 0 arguments, got 1",
+	}
+
+	for code, msg := range cases {
+		_, err := in.EvalString(code)
+		assert.EqualError(t, err, msg, code)
+	}
+
+	res, err := in.Rep(`(+)`)
+	assert.NoError(t, err)
+	assert.Equal(t, "0", res)
+}
+
+func TestBuiltin_Floats(t *testing.T) {
+	in := NewInterpreter(Options{})
+
+	cases := map[string]string{
+		"(+ 1 1.5)":   "2.5",
+		"(+ 1.5 1.5)": "3.0",
+		"(+ 1 2)":     "3",
+		"(- 1 0.5 2)": "-1.5",
+		"(* 2 0.25)":  "0.5",
+		"(/ 7 2)":     "3",
+		"(/ 7 2.0)":   "3.5",
+		"(/ 1.5)":     "1.5",
+		"(< 1 1.5)":   "true",
+		"(>= 2.0 2)":  "true",
+		"(> 0.5 1)":   "false",
+	}
+
+	for code, output := range cases {
+		res, err := in.Rep(code)
+		assert.NoError(t, err, code)
+		assert.Equal(t, output, res, code)
+	}
+
+	_, err := in.Rep("(/ 1 0.0)")
+	assert.EqualError(t, err, "/: division by zero")
+}
+
+func TestBuiltin_NoPanics(t *testing.T) {
+	var out bytes.Buffer
+	in := NewInterpreter(Options{
+		Stdout:       &out,
+		Stderr:       &out,
+		Capabilities: []Capability{CapCore, CapMath, CapStrings, CapIO},
+	})
+
+	args := []string{"", "1", `"a"`, ":k", "nil", "true", "(list)", "[1]", "{:a 1}", "1 2 3", `"a" "b" "c"`, `1 "a"`}
+	for name := range in.Env().defs {
+		for _, arg := range args {
+			code := "(" + name + " " + arg + ")"
+			assert.NotPanics(t, func() { in.EvalString(code) }, code)
+		}
+	}
+}
+
+func TestSignature_Arity(t *testing.T) {
+	assert.Equal(t, "1 argument", Signature{Params: []Type{TypeAny}}.arity())
+	assert.Equal(t, "2 to 4 arguments", Signature{Params: []Type{TypeAny, TypeAny}, Optional: []Type{TypeAny, TypeAny}}.arity())
+	assert.Equal(t, "at least 0 arguments", Signature{Rest: TypeAny}.arity())
+}
+
+func TestSignature_Convert(t *testing.T) {
+	sig := Signature{Params: []Type{TypeFunc, TypeAny}}
+	args := []Item{NewKeyword("a"), Hash{}}
+
+	converted := sig.convert(args)
+	assert.IsType(t, Func{}, converted[0])
+	assert.Equal(t, NewKeyword("a"), args[0], "args of the caller are kept")
+
+	args = []Item{Func{}, Hash{}}
+	assert.Same(t, &args[0], &sig.convert(args)[0])
+}
@@ -0,0 +1,530 @@
+package s
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Opcode is a single VM instruction, operands follow it in the code as
+// big endian uint16 values
+type Opcode byte
+
+const (
+	// OpConst k pushes constant k
+	OpConst Opcode = iota
+	// OpGlobal k pushes value of global named by symbol constant k
+	OpGlobal
+	// OpLocal k pushes value of local variable constant k
+	OpLocal
+	// OpDefine k defines global named by constant k as top of the stack
+	OpDefine
+	// OpBind k pops a value and binds it to pattern constant k
+	OpBind
+	// OpPop drops top of the stack
+	OpPop
+	// OpJump a continues at address a
+	OpJump
+	// OpJumpFalse a pops a value and jumps to a when it is falsy
+	OpJumpFalse
+	// OpJumpFalseKeep a jumps to a keeping a falsy value, pops a truthy one
+	OpJumpFalseKeep
+	// OpJumpTrueKeep a jumps to a keeping a truthy value, pops a falsy one
+	OpJumpTrueKeep
+	// OpMatch k a pops a value equal to any of list constant k, otherwise
+	// jumps to a keeping it
+	OpMatch
+	// OpNoMatch fails `case` with the value on top of the stack
+	OpNoMatch
+	// OpCall n s calls function below n arguments, s is the call site
+	OpCall
+	// OpClosure k pushes function of chunk constant k closed over the frame
+	OpClosure
+	// OpTry b c f runs chunks b, c and f as try body, catch and finally,
+	// noChunk marks a missing part
+	OpTry
+	// OpRequire k runs `require` with list constant k as arguments
+	OpRequire
+	// OpRefer k runs `refer` with list constant k as arguments
+	OpRefer
+	// OpReturn returns top of the stack
+	OpReturn
+)
+
+// noChunk is the operand of OpTry for a missing catch or finally
+const noChunk = 0xffff
+
+var opcodes = [...]struct {
+	name     string
+	operands int
+}{
+	OpConst:         {"CONST", 1},
+	OpGlobal:        {"GLOBAL", 1},
+	OpLocal:         {"LOCAL", 1},
+	OpDefine:        {"DEFINE", 1},
+	OpBind:          {"BIND", 1},
+	OpPop:           {"POP", 0},
+	OpJump:          {"JUMP", 1},
+	OpJumpFalse:     {"JUMP_FALSE", 1},
+	OpJumpFalseKeep: {"JUMP_FALSE_KEEP", 1},
+	OpJumpTrueKeep:  {"JUMP_TRUE_KEEP", 1},
+	OpMatch:         {"MATCH", 2},
+	OpNoMatch:       {"NO_MATCH", 0},
+	OpCall:          {"CALL", 2},
+	OpClosure:       {"CLOSURE", 1},
+	OpTry:           {"TRY", 3},
+	OpRequire:       {"REQUIRE", 1},
+	OpRefer:         {"REFER", 1},
+	OpReturn:        {"RETURN", 0},
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodes) {
+		return opcodes[op].name
+	}
+	return fmt.Sprintf("OP_%d", op)
+}
+
+// Chunk is compiled code of a top level form, a function or a part of
+// `try`. Function chunks bind their arguments to Params in a new frame,
+// catch handlers bind the exception to Params in the current one.
+type Chunk struct {
+	DefaultItem
+	Name   string
+	Code   []byte
+	Consts []Item
+	// Call sites referenced by OpCall, used for stack traces
+	Calls []Frame
+	// Number of slots a new frame for the chunk needs
+	Slots    int
+	Params   Item
+	Required int
+	Variadic bool
+}
+
+func (self *Chunk) Equal(i Item) Item {
+	return False{}
+}
+
+// operand reads operand starting at given offset
+func (self *Chunk) operand(at int) int {
+	return int(self.Code[at])<<8 | int(self.Code[at+1])
+}
+
+// Disassemble writes readable listing of the chunk and all nested chunks
+func (self *Chunk) Disassemble(w io.Writer) error {
+	var nested []*Chunk
+
+	header := fmt.Sprintf("chunk %s", self.Name)
+	if self.Slots > 0 {
+		header += fmt.Sprintf(" (slots %d)", self.Slots)
+	}
+	if _, err := fmt.Fprintln(w, header); err != nil {
+		return err
+	}
+
+	for pc := 0; pc < len(self.Code); {
+		op := Opcode(self.Code[pc])
+		if int(op) >= len(opcodes) {
+			return fmt.Errorf("unknown opcode %d at %d", op, pc)
+		}
+
+		line := fmt.Sprintf("  %04d %-16s", pc, op)
+		args := make([]int, opcodes[op].operands)
+		for i := range args {
+			args[i] = self.operand(pc + 1 + 2*i)
+			line += fmt.Sprintf(" %d", args[i])
+		}
+		pc += 1 + 2*len(args)
+
+		switch op {
+		case OpConst, OpGlobal, OpLocal, OpDefine, OpBind, OpMatch, OpRequire, OpRefer:
+			str, err := print(self.Consts[args[0]])
+			if err != nil {
+				return err
+			}
+			line += " ; " + str
+
+		case OpCall:
+			line += " ; " + self.Calls[args[1]].String()
+
+		case OpClosure:
+			chunk := self.Consts[args[0]].(*Chunk)
+			nested = append(nested, chunk)
+			line += " ; " + chunk.Name
+
+		case OpTry:
+			for _, k := range args {
+				if k != noChunk {
+					nested = append(nested, self.Consts[k].(*Chunk))
+				}
+			}
+		}
+
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+
+	for _, chunk := range nested {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+		if err := chunk.Disassemble(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verify checks that code of a loaded chunk cannot make the VM access
+// its stack, frames or constants out of bounds. Frames lists slots of
+// frames the chunk runs in, innermost first, nested chunks are verified
+// with the frames they run in.
+func (self *Chunk) verify(frames []int) error {
+	constant := func(k int, valid func(Item) bool) error {
+		if k >= len(self.Consts) || !valid(self.Consts[k]) {
+			return fmt.Errorf("invalid constant %d in chunk %s", k, self.Name)
+		}
+		return nil
+	}
+	anything := func(Item) bool { return true }
+	symbol := func(i Item) bool { _, ok := i.(Symbol); return ok }
+	local := func(i Item) bool { _, ok := i.(Local); return ok }
+	list := func(i Item) bool { _, ok := i.(List); return ok }
+	chunk := func(i Item) bool { _, ok := i.(*Chunk); return ok }
+
+	if err := self.verifyLocals(self.Params, frames); err != nil {
+		return err
+	}
+	if err := self.verifyPattern(self.Params); err != nil {
+		return err
+	}
+
+	type instruction struct {
+		op   Opcode
+		args []int
+	}
+	code := make([]*instruction, len(self.Code))
+	// Chunk constants run either as functions or as parts of try, each
+	// is verified once for the frames it runs in
+	nested := make(map[int]Opcode)
+
+	for pc := 0; pc < len(self.Code); {
+		op := Opcode(self.Code[pc])
+		if int(op) >= len(opcodes) {
+			return fmt.Errorf("unknown opcode %d at %d", op, pc)
+		}
+		if pc+1+2*opcodes[op].operands > len(self.Code) {
+			return fmt.Errorf("truncated instruction at %d", pc)
+		}
+
+		args := make([]int, opcodes[op].operands)
+		for i := range args {
+			args[i] = self.operand(pc + 1 + 2*i)
+		}
+		code[pc] = &instruction{op: op, args: args}
+		pc += 1 + 2*len(args)
+
+		var err error
+		switch op {
+		case OpConst:
+			err = constant(args[0], anything)
+		case OpBind:
+			if err = constant(args[0], anything); err == nil {
+				err = self.verifyLocals(self.Consts[args[0]], frames)
+			}
+			if err == nil {
+				err = self.verifyPattern(self.Consts[args[0]])
+			}
+		case OpGlobal, OpDefine:
+			err = constant(args[0], symbol)
+		case OpLocal:
+			if err = constant(args[0], local); err == nil {
+				err = self.verifyLocals(self.Consts[args[0]], frames)
+			}
+		case OpRequire, OpRefer:
+			err = constant(args[0], list)
+		case OpClosure:
+			if err = constant(args[0], chunk); err == nil {
+				c := self.Consts[args[0]].(*Chunk)
+				err = self.verifyNested(nested, op, args[0], append([]int{c.Slots}, frames...))
+			}
+		case OpMatch:
+			err = constant(args[0], list)
+		case OpCall:
+			if args[1] >= len(self.Calls) {
+				err = fmt.Errorf("invalid call site %d in chunk %s", args[1], self.Name)
+			}
+		case OpTry:
+			for i, k := range args {
+				if err == nil && (i == 0 || k != noChunk) {
+					if err = constant(k, chunk); err == nil {
+						err = self.verifyNested(nested, op, k, frames)
+					}
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Jumps land on instruction starts
+	for _, ins := range code {
+		if ins == nil {
+			continue
+		}
+
+		target := -1
+		switch ins.op {
+		case OpJump, OpJumpFalse, OpJumpFalseKeep, OpJumpTrueKeep:
+			target = ins.args[0]
+		case OpMatch:
+			target = ins.args[1]
+		}
+		if target >= 0 && (target >= len(self.Code) || code[target] == nil) {
+			return fmt.Errorf("invalid jump to %d in chunk %s", target, self.Name)
+		}
+	}
+
+	// Follow every path through the code, the stack has to hold operands
+	// of each instruction and the same number of items wherever paths join
+	heights := make([]int, len(self.Code))
+	for i := range heights {
+		heights[i] = -1
+	}
+	var work []int
+	flow := func(to int, height int) error {
+		switch {
+		case to >= len(self.Code):
+			return fmt.Errorf("chunk %s does not end with return", self.Name)
+		case heights[to] < 0:
+			heights[to] = height
+			work = append(work, to)
+		case heights[to] != height:
+			return fmt.Errorf("inconsistent stack at %d in chunk %s", to, self.Name)
+		}
+		return nil
+	}
+
+	if err := flow(0, 0); err != nil {
+		return err
+	}
+	for len(work) > 0 {
+		pc := work[len(work)-1]
+		work = work[:len(work)-1]
+		ins, height := code[pc], heights[pc]
+		next := pc + 1 + 2*len(ins.args)
+
+		need := 0
+		switch ins.op {
+		case OpDefine, OpBind, OpPop, OpJumpFalse, OpJumpFalseKeep, OpJumpTrueKeep, OpMatch, OpNoMatch, OpReturn:
+			need = 1
+		case OpCall:
+			need = ins.args[0] + 1
+		}
+		if height < need {
+			return fmt.Errorf("stack underflow at %d in chunk %s", pc, self.Name)
+		}
+
+		var err error
+		switch ins.op {
+		case OpReturn, OpNoMatch:
+		case OpJump:
+			err = flow(ins.args[0], height)
+		case OpJumpFalse:
+			if err = flow(ins.args[0], height-1); err == nil {
+				err = flow(next, height-1)
+			}
+		case OpJumpFalseKeep, OpJumpTrueKeep:
+			if err = flow(ins.args[0], height); err == nil {
+				err = flow(next, height-1)
+			}
+		case OpMatch:
+			if err = flow(ins.args[1], height); err == nil {
+				err = flow(next, height-1)
+			}
+		case OpDefine:
+			err = flow(next, height)
+		case OpBind, OpPop:
+			err = flow(next, height-1)
+		case OpCall:
+			err = flow(next, height-ins.args[0])
+		default:
+			err = flow(next, height+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyNested verifies chunk constant k run by op in given frames
+func (self *Chunk) verifyNested(nested map[int]Opcode, op Opcode, k int, frames []int) error {
+	if prev, ok := nested[k]; ok {
+		if prev != op {
+			return fmt.Errorf("invalid constant %d in chunk %s", k, self.Name)
+		}
+		return nil
+	}
+	nested[k] = op
+	return self.Consts[k].(*Chunk).verify(frames)
+}
+
+// verifyLocals checks that locals of item, e.g. a pattern, refer to
+// existing slots of given frames
+func (self *Chunk) verifyLocals(item Item, frames []int) error {
+	switch v := item.(type) {
+	case Local:
+		if v.Depth >= len(frames) || v.Index >= frames[v.Depth] {
+			return fmt.Errorf("invalid local %s in chunk %s", v.Name, self.Name)
+		}
+	case List, Vector:
+		items, _ := seqItems(v)
+		for _, elem := range items {
+			if err := self.verifyLocals(elem, frames); err != nil {
+				return err
+			}
+		}
+	case Hash:
+		for _, kv := range v.Value {
+			if err := self.verifyLocals(kv.Key, frames); err != nil {
+				return err
+			}
+			if err := self.verifyLocals(kv.Value, frames); err != nil {
+				return err
+			}
+		}
+	case Lambda:
+		inner := append([]int{v.Slots}, frames...)
+		if err := self.verifyLocals(v.Params, inner); err != nil {
+			return err
+		}
+		return self.verifyLocals(List{Value: v.Body}, inner)
+	}
+	return nil
+}
+
+// verifyPattern checks forms which binding pattern item evaluates, the
+// defaults of its :or, as they run in the tree walker
+func (self *Chunk) verifyPattern(item Item) error {
+	switch v := item.(type) {
+	case Vector:
+		for _, elem := range v.Value {
+			if err := self.verifyPattern(elem); err != nil {
+				return err
+			}
+		}
+	case Hash:
+		for _, kv := range v.Value {
+			kw, ok := kv.Key.(Keyword)
+			if !ok {
+				if err := self.verifyPattern(kv.Key); err != nil {
+					return err
+				}
+				continue
+			}
+
+			defaults, ok := kv.Value.(Hash)
+			if kw.Value != "or" || !ok {
+				continue
+			}
+			for _, d := range defaults.Value {
+				if err := self.verifyForm(d.Value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// verifyForm checks that special forms of analyzed item have the shape
+// the tree walker expects
+func (self *Chunk) verifyForm(item Item) error {
+	var forms []Item
+
+	switch v := item.(type) {
+	case Lambda:
+		if err := self.verifyPattern(v.Params); err != nil {
+			return err
+		}
+		forms = v.Body
+
+	case List:
+		if len(v.Value) == 0 {
+			return nil
+		}
+		head, _ := v.Value[0].(Symbol)
+		args := v.Value[1:]
+		forms = v.Value
+
+		invalid := fmt.Errorf("invalid form %s in chunk %s", printKey(item), self.Name)
+		switch head.Value {
+		case "set":
+			if len(args) != 2 || !args[0].IsSymbol() {
+				return invalid
+			}
+			forms = args[1:]
+
+		case "let":
+			if len(args) == 0 {
+				return invalid
+			}
+			bindings, ok := args[0].(Vector)
+			if !ok || len(bindings.Value)%2 != 0 {
+				return invalid
+			}
+			forms = nil
+			for i := 0; i < len(bindings.Value); i += 2 {
+				if err := self.verifyPattern(bindings.Value[i]); err != nil {
+					return err
+				}
+				forms = append(forms, bindings.Value[i+1])
+			}
+			forms = append(forms, args[1:]...)
+
+		case "if":
+			if len(args) < 2 || len(args) > 3 {
+				return invalid
+			}
+			forms = args
+
+		case "case":
+			// Constants of clauses are not evaluated
+			forms = nil
+			for i, arg := range args {
+				if i == 0 || i%2 == 0 || i == len(args)-1 {
+					forms = append(forms, arg)
+				}
+			}
+
+		case "try":
+			t, err := parseTry(args)
+			if err != nil {
+				// Reported when evaluated
+				return nil
+			}
+			if len(t.catch) > 0 {
+				if err := self.verifyPattern(t.catch[0]); err != nil {
+					return err
+				}
+				forms = append(append(t.body, t.catch[1:]...), t.finally...)
+			} else {
+				forms = append(t.body, t.finally...)
+			}
+
+		case "require", "refer":
+			forms = nil
+		}
+	}
+
+	for _, form := range forms {
+		if err := self.verifyForm(form); err != nil {
+			return err
+		}
+	}
+	return nil
+}
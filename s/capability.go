@@ -0,0 +1,182 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Capability is a named set of builtins an interpreter can be given
+type Capability string
+
+const (
+	// CapCore holds lists, equality, exceptions and reading of code
+	CapCore Capability = "core"
+	// CapMath holds integer arithmetic and comparison
+	CapMath Capability = "math"
+	// CapStrings holds string manipulation
+	CapStrings Capability = "strings"
+	// CapIO holds printing to interpreter outputs
+	CapIO Capability = "io"
+	// CapOS holds access to files, environment and processes
+	CapOS Capability = "os"
+	// CapTime holds reading the clock and sleeping
+	CapTime Capability = "time"
+	// CapNet holds network access
+	CapNet Capability = "net"
+	// CapAsync holds goroutines and channels
+	CapAsync Capability = "async"
+)
+
+// AllCapabilities is the default profile of a trusted interpreter
+var AllCapabilities = []Capability{CapCore, CapMath, CapStrings, CapIO, CapOS, CapTime, CapNet, CapAsync}
+
+// Sandbox is a deny-by-default profile for untrusted code. It only has
+// core functions, anything else must be granted explicitly, e.g.
+//
+//	Options{Capabilities: append(s.Sandbox, s.CapMath, s.CapStrings)}
+//
+// `require` still loads modules from Options.Path, which is left to the
+// embedder.
+var Sandbox = []Capability{CapCore}
+
+// capabilities maps every capability to a function installing it
+var capabilities = map[Capability]func(in *Interpreter){
+	CapCore:    func(in *Interpreter) { in.env.initCore() },
+	CapMath:    func(in *Interpreter) { in.env.initMath() },
+	CapStrings: func(in *Interpreter) { in.env.initStrings() },
+	CapIO:      (*Interpreter).initIO,
+	CapOS:      (*Interpreter).initOS,
+	CapTime:    func(in *Interpreter) { in.env.initTime() },
+	CapNet:     func(in *Interpreter) { in.env.initNet() },
+	CapAsync:   func(in *Interpreter) { in.env.initAsync() },
+}
+
+// grant installs builtins of given capabilities
+func (in *Interpreter) grant(caps []Capability) {
+	for _, c := range caps {
+		install, ok := capabilities[c]
+		if !ok {
+			panic(fmt.Sprintf("unknown capability %q", c))
+		}
+		install(in)
+	}
+}
+
+// initStrings sets up string manipulation
+func (e *Env) initStrings() {
+	e.DefineBuiltin("str", Signature{Rest: TypeAny}, func(ctx context.Context, args []Item) (Item, error) {
+		var out strings.Builder
+		for _, arg := range args {
+			if arg.IsNil() {
+				continue
+			}
+
+			str, err := display(ctx, arg, false)
+			if err != nil {
+				return nil, err
+			}
+			out.WriteString(str)
+		}
+
+		if err := checkSize(ctx, out.Len()); err != nil {
+			return nil, err
+		}
+		return String{Value: out.String()}, nil
+	})
+
+	e.DefineBuiltin("string?", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		if args[0].IsString() {
+			return True{}, nil
+		}
+		return False{}, nil
+	})
+
+	subs := Signature{Params: []Type{TypeString, TypeInteger}, Optional: []Type{TypeInteger}}
+	e.DefineBuiltin("subs", subs, func(ctx context.Context, args []Item) (Item, error) {
+		runes := []rune(args[0].(String).Value)
+
+		start, end := int(args[1].(Integer).Value), len(runes)
+		if len(args) > 2 {
+			end = int(args[2].(Integer).Value)
+		}
+
+		if start < 0 || end > len(runes) || start > end {
+			return nil, fmt.Errorf("subs: bounds [%d %d] out of range for length %d", start, end, len(runes))
+		}
+		return String{Value: string(runes[start:end])}, nil
+	})
+
+	unary := func(name string, fn func(string) string) {
+		e.DefineBuiltin(name, Signature{Params: []Type{TypeString}}, func(ctx context.Context, args []Item) (Item, error) {
+			return String{Value: fn(args[0].(String).Value)}, nil
+		})
+	}
+	unary("upper-case", strings.ToUpper)
+	unary("lower-case", strings.ToLower)
+	unary("trim", strings.TrimSpace)
+
+	strPair := Signature{Params: []Type{TypeString, TypeString}}
+	predicate := func(name string, fn func(string, string) bool) {
+		e.DefineBuiltin(name, strPair, func(ctx context.Context, args []Item) (Item, error) {
+			if fn(args[0].(String).Value, args[1].(String).Value) {
+				return True{}, nil
+			}
+			return False{}, nil
+		})
+	}
+	predicate("includes?", strings.Contains)
+	predicate("starts-with?", strings.HasPrefix)
+	predicate("ends-with?", strings.HasSuffix)
+
+	e.DefineBuiltin("split", strPair, func(ctx context.Context, args []Item) (Item, error) {
+		parts := strings.Split(args[0].(String).Value, args[1].(String).Value)
+		if err := checkSize(ctx, len(parts)); err != nil {
+			return nil, err
+		}
+
+		items := make([]Item, len(parts))
+		for i, part := range parts {
+			items[i] = String{Value: part}
+		}
+		return List{Value: items}, nil
+	})
+
+	e.DefineBuiltin("join", Signature{Params: []Type{TypeString, TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		items, err := realize(ctx, args[1].(Seq))
+		if err != nil {
+			return nil, err
+		}
+
+		strs := make([]string, len(items))
+		for i, item := range items {
+			str, ok := item.(String)
+			if !ok {
+				return nil, fmt.Errorf("join: item %d expected string, got %s", i+1, typeName(item))
+			}
+			strs[i] = str.Value
+		}
+
+		return String{Value: strings.Join(strs, args[0].(String).Value)}, nil
+	})
+}
+
+// initTime sets up reading of the clock
+func (e *Env) initTime() {
+	e.DefineBuiltin("now", Signature{}, func(ctx context.Context, args []Item) (Item, error) {
+		return Integer{Value: time.Now().UnixMilli()}, nil
+	})
+
+	e.DefineBuiltin("sleep", Signature{Params: []Type{TypeInteger}}, func(ctx context.Context, args []Item) (Item, error) {
+		timer := time.NewTimer(time.Duration(args[0].(Integer).Value) * time.Millisecond)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return Nil{}, nil
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+	})
+}
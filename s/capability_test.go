@@ -0,0 +1,74 @@
+package s
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities_Sandbox(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret.slang")
+	assert.NoError(t, os.WriteFile(secret, []byte("(set leaked :yes)"), 0o644))
+
+	var out bytes.Buffer
+	in := NewInterpreter(Options{Stdout: &out, Capabilities: Sandbox})
+
+	for _, code := range []string{
+		fmt.Sprintf("(load-file %q)", secret),
+		fmt.Sprintf("(slurp %q)", secret),
+		fmt.Sprintf("(spit %q \"\")", secret),
+		`(sh "echo" "hi")`,
+		`(getenv "HOME")`,
+		`(http-get "http://localhost")`,
+		`(println "hi")`,
+		`(require [secret])`,
+	} {
+		_, err := in.EvalString(code)
+		assert.Error(t, err, code)
+	}
+	assert.Empty(t, out.String())
+
+	// Nothing installed by os, net or io is reachable from the sandbox
+	base := NewInterpreter(Options{Capabilities: []Capability{}})
+	trusted := NewInterpreter(Options{Capabilities: []Capability{CapOS, CapNet, CapIO}})
+	for name := range trusted.Env().defs {
+		if _, ok := base.Env().defs[name]; ok {
+			continue
+		}
+		_, err := in.EvalString(name)
+		assert.EqualError(t, err, name+" is undefined")
+	}
+
+	res, err := in.Rep("(try (throw (ex-info \"no\" {})) (catch e (ex-message e)))")
+	assert.NoError(t, err)
+	assert.Equal(t, `"no"`, res)
+}
+
+func TestCapabilities_Grant(t *testing.T) {
+	in := NewInterpreter(Options{Capabilities: append(Sandbox, CapMath, CapStrings)})
+
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{`(+ 1 2)`, "3"},
+		{`(str "a" 1 nil :b)`, `"a1:b"`},
+		{`(upper-case (subs "hello" 1 3))`, `"EL"`},
+		{`(join "-" (split "a,b,c" ","))`, `"a-b-c"`},
+		{`(starts-with? "slang" "sl")`, "true"},
+	}
+	for _, c := range cases {
+		res, err := in.Rep(c.input)
+		assert.NoError(t, err, c.input)
+		assert.Equal(t, c.output, res, c.input)
+	}
+
+	_, err := in.EvalString("(now)")
+	assert.EqualError(t, err, "now is undefined")
+
+	assert.Panics(t, func() { NewInterpreter(Options{Capabilities: []Capability{"fs"}}) })
+}
@@ -0,0 +1,519 @@
+package s
+
+import (
+	"context"
+	"fmt"
+)
+
+// Backend selects how analyzed code is executed
+type Backend int
+
+const (
+	// BackendTree walks the analyzed form on every evaluation
+	BackendTree Backend = iota
+	// BackendClosure compiles the analyzed form into Go closures once
+	BackendClosure
+	// BackendVM compiles the analyzed form into bytecode run by a stack VM
+	BackendVM
+)
+
+// backendOf returns backend configured for interpreter env belongs to
+func backendOf(env *Env) Backend {
+	if ns, err := namespaceOf(env); err == nil {
+		return ns.in.backend
+	}
+	return BackendTree
+}
+
+// compiled is a form compiled into a tree of Go closures
+type compiled func(ctx context.Context, env *Env) (Item, error)
+
+// compile turns analyzed form into closures. Special forms are dispatched
+// and constants prepared here, so running the result only does the work
+// left for runtime.
+func compile(node Item) (compiled, error) {
+	switch v := node.(type) {
+	case Symbol:
+		name := v.Value
+		return func(ctx context.Context, env *Env) (Item, error) {
+			return env.Get(name)
+		}, nil
+
+	case Local:
+		return compileLocal(v), nil
+
+	case Lambda:
+		return compileLambda(v)
+
+	case List:
+		return compileList(v)
+
+	default:
+		return constant(v), nil
+	}
+}
+
+func constant(item Item) compiled {
+	return func(context.Context, *Env) (Item, error) {
+		return item, nil
+	}
+}
+
+func compileAll(nodes []Item) ([]compiled, error) {
+	code := make([]compiled, len(nodes))
+	for i, node := range nodes {
+		c, err := compile(node)
+		if err != nil {
+			return nil, err
+		}
+		code[i] = c
+	}
+	return code, nil
+}
+
+// compileBody compiles forms evaluated in order, returning the last result
+func compileBody(nodes []Item) (compiled, error) {
+	switch len(nodes) {
+	case 0:
+		return constant(Nil{}), nil
+	case 1:
+		return compile(nodes[0])
+	}
+
+	code, err := compileAll(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		var result Item
+		for _, c := range code {
+			var err error
+			if result, err = c(ctx, env); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}, nil
+}
+
+func compileLocal(l Local) compiled {
+	index, depth, name := l.Index, l.Depth, l.Name
+
+	if depth == 0 {
+		return func(ctx context.Context, env *Env) (Item, error) {
+			if item := env.slots[index]; item != nil {
+				return item, nil
+			}
+			return nil, fmt.Errorf("%s is undefined", name)
+		}
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		frame := env
+		for i := 0; i < depth; i++ {
+			frame = frame.parent
+		}
+
+		if item := frame.slots[index]; item != nil {
+			return item, nil
+		}
+		return nil, fmt.Errorf("%s is undefined", name)
+	}
+}
+
+func compileLambda(fn Lambda) (compiled, error) {
+	body, err := compileBody(fn.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		return Func{Value: func(ctx context.Context, args []Item) (Item, error) {
+			if len(args) < fn.Required || (!fn.Variadic && len(args) > fn.Required) {
+				return nil, fmt.Errorf("wrong number of args (%d) passed to fn", len(args))
+			}
+
+			b := budgetOf(ctx)
+			if err := b.enter(); err != nil {
+				return nil, err
+			}
+			defer b.leave()
+
+			frame := env.newFrame(fn.Slots)
+			if err := bind(ctx, fn.Params, List{Value: args}, frame); err != nil {
+				return nil, err
+			}
+
+			return body(ctx, frame)
+		}}, nil
+	}, nil
+}
+
+func compileList(list List) (compiled, error) {
+	if len(list.Value) == 0 {
+		return constant(list), nil
+	}
+
+	head, ok := list.Value[0].(Symbol)
+	if !ok || !specialForms[head.Value] {
+		return compileCall(list)
+	}
+
+	rest := list.Value[1:]
+	switch head.Value {
+	case "set":
+		return compileSet(rest)
+
+	case "let":
+		return compileLet(rest)
+
+	case "do":
+		return compileBody(rest)
+
+	case "if":
+		return compileIf(rest)
+
+	case "when":
+		return compileWhen(rest, true)
+
+	case "unless":
+		return compileWhen(rest, false)
+
+	case "and":
+		return compileLogic(rest, false)
+
+	case "or":
+		return compileLogic(rest, true)
+
+	case "cond":
+		return compileCond(rest)
+
+	case "case":
+		return compileCase(rest)
+
+	case "try":
+		return compileTry(rest)
+
+	case "ns":
+		return nil, fmt.Errorf("ns is only allowed at top level")
+
+	case "require":
+		return func(ctx context.Context, env *Env) (Item, error) {
+			return evalRequire(ctx, rest, env)
+		}, nil
+
+	case "refer":
+		return func(ctx context.Context, env *Env) (Item, error) {
+			return evalRefer(ctx, rest, env)
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("cannot compile special form %s", head.Value)
+	}
+}
+
+func compileCall(list List) (compiled, error) {
+	head, err := compile(list.Value[0])
+	if err != nil {
+		return nil, err
+	}
+	args, err := compileAll(list.Value[1:])
+	if err != nil {
+		return nil, err
+	}
+	name := callName(list.Value[0])
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		fn, err := head(ctx, env)
+		if err != nil {
+			return nil, err
+		}
+
+		f, ok := callable(fn)
+		if !ok {
+			return nil, fmt.Errorf("Unexpected type of %v", fn)
+		}
+
+		values := make([]Item, len(args))
+		for i, arg := range args {
+			if values[i], err = arg(ctx, env); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := step(ctx); err != nil {
+			return nil, err
+		}
+
+		val, err := f.Value(ctx, values)
+		if err != nil {
+			return nil, withFrame(err, name, list)
+		}
+		return val, nil
+	}, nil
+}
+
+func compileSet(rest []Item) (compiled, error) {
+	name := rest[0].(Symbol).Value
+	value, err := compile(rest[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		val, err := value(ctx, env)
+		if err != nil {
+			return nil, err
+		}
+
+		env.globals().Define(name, val)
+		return val, nil
+	}, nil
+}
+
+func compileLet(rest []Item) (compiled, error) {
+	bindings := rest[0].(Vector).Value
+
+	patterns := make([]Item, 0, len(bindings)/2)
+	values := make([]compiled, 0, len(bindings)/2)
+	for i := 0; i < len(bindings); i += 2 {
+		value, err := compile(bindings[i+1])
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, bindings[i])
+		values = append(values, value)
+	}
+
+	body, err := compileBody(rest[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		for i, value := range values {
+			val, err := value(ctx, env)
+			if err != nil {
+				return nil, err
+			}
+			if err := bind(ctx, patterns[i], val, env); err != nil {
+				return nil, err
+			}
+		}
+		return body(ctx, env)
+	}, nil
+}
+
+func compileIf(rest []Item) (compiled, error) {
+	if len(rest) < 2 {
+		return nil, fmt.Errorf("if expects a condition and a branch")
+	}
+
+	code, err := compileAll(rest)
+	if err != nil {
+		return nil, err
+	}
+	cond, ifTrue, ifFalse := code[0], code[1], constant(Nil{})
+	if len(code) == 3 {
+		ifFalse = code[2]
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		test, err := cond(ctx, env)
+		if err != nil {
+			return nil, err
+		}
+
+		if !Truthy(test) {
+			return ifFalse(ctx, env)
+		}
+		return ifTrue(ctx, env)
+	}, nil
+}
+
+func compileWhen(rest []Item, expected bool) (compiled, error) {
+	if len(rest) == 0 {
+		return nil, fmt.Errorf("missing condition")
+	}
+
+	cond, err := compile(rest[0])
+	if err != nil {
+		return nil, err
+	}
+	body, err := compileBody(rest[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		test, err := cond(ctx, env)
+		if err != nil {
+			return nil, err
+		}
+
+		if Truthy(test) != expected {
+			return Nil{}, nil
+		}
+		return body(ctx, env)
+	}, nil
+}
+
+// compileLogic compiles `and` and `or`, which stop at the first value
+// whose truthiness equals stop
+func compileLogic(rest []Item, stop bool) (compiled, error) {
+	var empty Item = True{}
+	if stop {
+		empty = Nil{}
+	}
+
+	code, err := compileAll(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		result := empty
+		for _, c := range code {
+			var err error
+			if result, err = c(ctx, env); err != nil {
+				return nil, err
+			}
+
+			if Truthy(result) == stop {
+				return result, nil
+			}
+		}
+		return result, nil
+	}, nil
+}
+
+func compileCond(rest []Item) (compiled, error) {
+	if len(rest)%2 != 0 {
+		return nil, fmt.Errorf("cond expects an even number of forms")
+	}
+
+	code, err := compileAll(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		for i := 0; i < len(code); i += 2 {
+			test, err := code[i](ctx, env)
+			if err != nil {
+				return nil, err
+			}
+
+			if Truthy(test) {
+				return code[i+1](ctx, env)
+			}
+		}
+		return Nil{}, nil
+	}, nil
+}
+
+func compileCase(rest []Item) (compiled, error) {
+	expr, err := compile(rest[0])
+	if err != nil {
+		return nil, err
+	}
+
+	type clause struct {
+		consts []Item
+		result compiled
+	}
+
+	var clauses []clause
+	fallback := compiled(nil)
+
+	forms := rest[1:]
+	for i := 0; i < len(forms); i += 2 {
+		if i+1 == len(forms) {
+			if fallback, err = compile(forms[i]); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		c := clause{consts: []Item{forms[i]}}
+		if list, ok := forms[i].(List); ok {
+			c.consts = list.Value
+		}
+		if c.result, err = compile(forms[i+1]); err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, c)
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		value, err := expr(ctx, env)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range clauses {
+			for _, k := range c.consts {
+				if k.Equal(value).IsTrue() {
+					return c.result(ctx, env)
+				}
+			}
+		}
+
+		if fallback != nil {
+			return fallback(ctx, env)
+		}
+
+		str, err := printContext(ctx, value)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no matching clause: %s", str)
+	}, nil
+}
+
+func compileTry(rest []Item) (compiled, error) {
+	t, err := parseTry(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := compileBody(t.body)
+	if err != nil {
+		return nil, err
+	}
+
+	var handler, cleanup compiled
+	if t.catch != nil {
+		if handler, err = compileBody(t.catch[1:]); err != nil {
+			return nil, err
+		}
+	}
+	if t.finally != nil {
+		if cleanup, err = compileBody(t.finally); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(ctx context.Context, env *Env) (Item, error) {
+		var onError func(Item) (Item, error)
+		if handler != nil {
+			onError = func(value Item) (Item, error) {
+				if err := bind(ctx, t.catch[0], value, env); err != nil {
+					return nil, err
+				}
+				return handler(ctx, env)
+			}
+		}
+
+		var onExit func() error
+		if cleanup != nil {
+			onExit = func() error {
+				_, err := cleanup(ctx, env)
+				return err
+			}
+		}
+
+		return runTry(func() (Item, error) { return body(ctx, env) }, onError, onExit)
+	}, nil
+}
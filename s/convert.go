@@ -0,0 +1,449 @@
+package s
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+var (
+	anyType  = reflect.TypeOf((*any)(nil)).Elem()
+	itemType = reflect.TypeOf((*Item)(nil)).Elem()
+)
+
+// ToGo converts item to the Go value closest to it: nil, bool, int64,
+// float64, string for strings, keywords and symbols, []any for lists and
+// vectors and map[string]any for hashes with keyword or string keys.
+// Other items, e.g. functions, are returned as they are.
+func ToGo(item Item) (any, error) {
+	switch v := item.(type) {
+	case nil, Nil:
+		return nil, nil
+	case True:
+		return true, nil
+	case False:
+		return false, nil
+	case Integer:
+		return v.Value, nil
+	case Float:
+		return v.Value, nil
+	case String:
+		return v.Value, nil
+	case Keyword:
+		return v.Value, nil
+	case Symbol:
+		return v.Value, nil
+
+	case List, Vector:
+		items, _ := seqItems(v)
+		values := make([]any, len(items))
+		for i, elem := range items {
+			value, err := ToGo(elem)
+			if err != nil {
+				return nil, fmt.Errorf("item %d %w", i+1, err)
+			}
+			values[i] = value
+		}
+		return values, nil
+
+	case Hash:
+		values := make(map[string]any, len(v.Value))
+		for _, kv := range v.Value {
+			var key string
+			switch k := kv.Key.(type) {
+			case Keyword:
+				key = k.Value
+			case String:
+				key = k.Value
+			default:
+				return nil, fmt.Errorf("key %s expected keyword or string", printKey(kv.Key))
+			}
+
+			value, err := ToGo(kv.Value)
+			if err != nil {
+				return nil, fmt.Errorf("key %s %w", printKey(kv.Key), err)
+			}
+			values[key] = value
+		}
+		return values, nil
+
+	default:
+		return item, nil
+	}
+}
+
+// FromGo converts Go value to an item. Numbers, strings and booleans
+// become atoms, slices and arrays become lists, maps and structs become
+// hashes and nil, nil pointers, slices and maps become nil. String keys
+// of maps become keywords, see Unmarshal for names of struct fields.
+// Items are returned as they are.
+func FromGo(value any) (Item, error) {
+	return fromGoValue(reflect.ValueOf(value))
+}
+
+// Unmarshal decodes item into value pointed to by v. It is the inverse
+// of FromGo: hashes are decoded into maps, whose string keys may be
+// given as keywords, and into structs. Struct fields are named by
+// keywords in kebab case, e.g. `:user-name` for UserName, unless a
+// `slang:"name"` tag names them, `slang:"-"` skips a field. Keys not
+// matching any field are ignored and fields without keys keep their
+// values.
+func Unmarshal(item Item, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("unmarshal expects a non-nil pointer, got %T", v)
+	}
+	return decode(item, rv.Elem())
+}
+
+// toGoValue converts item to a value of Go type t
+func toGoValue(item Item, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	if err := decode(item, v); err != nil {
+		return reflect.Value{}, err
+	}
+	return v, nil
+}
+
+// decode sets settable v to converted item
+func decode(item Item, v reflect.Value) error {
+	if item == nil {
+		item = Nil{}
+	}
+
+	t := v.Type()
+	if reflect.TypeOf(item).AssignableTo(t) && t != anyType {
+		v.Set(reflect.ValueOf(item))
+		return nil
+	}
+	// Host values are passed back unwrapped
+	if host, ok := item.(GoValue); ok && host.Value != nil && reflect.TypeOf(host.Value).AssignableTo(t) {
+		v.Set(reflect.ValueOf(host.Value))
+		return nil
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("expected %s, got %s", t, typeName(item))
+	}
+
+	if item.IsNil() {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		return mismatch()
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		natural, err := ToGo(item)
+		if err != nil {
+			return err
+		}
+		if natural == nil || !reflect.TypeOf(natural).AssignableTo(t) {
+			return mismatch()
+		}
+		v.Set(reflect.ValueOf(natural))
+
+	case reflect.Bool:
+		switch item.(type) {
+		case True:
+			v.SetBool(true)
+		case False:
+			v.SetBool(false)
+		default:
+			return mismatch()
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := item.(Integer)
+		if !ok {
+			return mismatch()
+		}
+		if v.OverflowInt(n.Value) {
+			return fmt.Errorf("expected %s, got %d out of range", t, n.Value)
+		}
+		v.SetInt(n.Value)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := item.(Integer)
+		if !ok {
+			return mismatch()
+		}
+		if n.Value < 0 || v.OverflowUint(uint64(n.Value)) {
+			return fmt.Errorf("expected %s, got %d out of range", t, n.Value)
+		}
+		v.SetUint(uint64(n.Value))
+
+	case reflect.Float32, reflect.Float64:
+		switch n := item.(type) {
+		case Float:
+			v.SetFloat(n.Value)
+		case Integer:
+			v.SetFloat(float64(n.Value))
+		default:
+			return mismatch()
+		}
+
+	case reflect.String:
+		str, ok := item.(String)
+		if !ok {
+			return mismatch()
+		}
+		v.SetString(str.Value)
+
+	case reflect.Slice, reflect.Array:
+		if !item.IsList() && !item.IsVector() {
+			return mismatch()
+		}
+		items, _ := seqItems(item)
+
+		if t.Kind() == reflect.Array && len(items) != t.Len() {
+			return fmt.Errorf("expected %s, got %d items", t, len(items))
+		}
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, len(items), len(items)))
+		}
+
+		for i, elem := range items {
+			if err := decode(elem, v.Index(i)); err != nil {
+				return fmt.Errorf("item %d %w", i+1, err)
+			}
+		}
+
+	case reflect.Map:
+		hash, ok := item.(Hash)
+		if !ok {
+			return mismatch()
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(t, len(hash.Value)))
+		}
+		for _, kv := range hash.Value {
+			key := kv.Key
+			// Keywords are the usual keys of slang hashes
+			if kw, ok := key.(Keyword); ok && t.Key().Kind() == reflect.String {
+				key = String{Value: kw.Value}
+			}
+
+			mk, err := toGoValue(key, t.Key())
+			if err != nil {
+				return fmt.Errorf("key %w", err)
+			}
+			mv, err := toGoValue(kv.Value, t.Elem())
+			if err != nil {
+				return fmt.Errorf("key %s %w", printKey(kv.Key), err)
+			}
+			v.SetMapIndex(mk, mv)
+		}
+
+	case reflect.Struct:
+		hash, ok := item.(Hash)
+		if !ok {
+			return mismatch()
+		}
+
+		for _, f := range structFields(t) {
+			value, ok := hash.Get(NewKeyword(f.name))
+			if !ok {
+				continue
+			}
+			fv, err := fieldOf(v, f.index)
+			if err == nil {
+				err = decode(value, fv)
+			}
+			if err != nil {
+				return fmt.Errorf("field :%s %w", f.name, err)
+			}
+		}
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return decode(item, v.Elem())
+
+	default:
+		return mismatch()
+	}
+
+	return nil
+}
+
+// fromGoValue converts Go value to an item
+func fromGoValue(v reflect.Value) (Item, error) {
+	if !v.IsValid() {
+		return Nil{}, nil
+	}
+	if v.Type().Implements(itemType) {
+		if v.Kind() == reflect.Interface && v.IsNil() {
+			return Nil{}, nil
+		}
+		return v.Interface().(Item), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return True{}, nil
+		}
+		return False{}, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := v.Uint()
+		if n > 1<<63-1 {
+			return nil, fmt.Errorf("%d out of integer range", n)
+		}
+		return Integer{Value: int64(n)}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return Float{Value: v.Float()}, nil
+
+	case reflect.String:
+		return String{Value: v.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return Nil{}, nil
+		}
+
+		items := make([]Item, v.Len())
+		for i := range items {
+			item, err := fromGoValue(v.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("item %d %w", i+1, err)
+			}
+			items[i] = item
+		}
+		return List{Value: items}, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return Nil{}, nil
+		}
+
+		hash := Hash{}
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := fromGoValue(iter.Key())
+			if err != nil {
+				return nil, fmt.Errorf("key %w", err)
+			}
+			if str, ok := key.(String); ok {
+				key = NewKeyword(str.Value)
+			}
+
+			value, err := fromGoValue(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("key %s %w", printKey(key), err)
+			}
+			hash = hash.Add(KeyValue{Key: key, Value: value})
+		}
+		return hash, nil
+
+	case reflect.Struct:
+		hash := Hash{}
+		for _, f := range structFields(v.Type()) {
+			// Fields of nil embedded structs are left out
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil || f.omitEmpty && fv.IsZero() {
+				continue
+			}
+
+			value, err := fromGoValue(fv)
+			if err != nil {
+				return nil, fmt.Errorf("field :%s %w", f.name, err)
+			}
+			hash = hash.Add(KeyValue{Key: NewKeyword(f.name), Value: value})
+		}
+		return hash, nil
+
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return Nil{}, nil
+		}
+		return fromGoValue(v.Elem())
+
+	default:
+		return nil, fmt.Errorf("cannot convert %s", v.Type())
+	}
+}
+
+// field is an exported struct field as seen from slang
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields returns fields of struct type t which are converted,
+// fields of embedded structs are included
+func structFields(t reflect.Type) []field {
+	var fields []field
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("slang"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = kebabCase(f.Name)
+		}
+
+		fields = append(fields, field{name: name, index: f.Index, omitEmpty: opts == "omitempty"})
+	}
+	return fields
+}
+
+// fieldOf returns field of struct v at index, nil embedded structs on
+// the way are allocated
+func fieldOf(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot allocate embedded %s", v.Type())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// kebabCase returns keyword name of Go name, UserName is user-name
+func kebabCase(name string) string {
+	var out strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Acronyms stay together, e.g. HTTPServer is http-server
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])) {
+				out.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+// printKey returns printed item, e.g. a hash key, for error messages
+func printKey(key Item) string {
+	str, err := print(key)
+	if err != nil {
+		return typeName(key)
+	}
+	return str
+}
@@ -0,0 +1,128 @@
+package s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type address struct {
+	City string `slang:"town"`
+}
+
+type user struct {
+	Name    string
+	UserID  int64
+	Score   float64 `slang:",omitempty"`
+	Tags    []string
+	Home    *address
+	Secret  string `slang:"-"`
+	Extra   map[string]any
+	private int
+}
+
+type Endpoint struct {
+	Port int64
+}
+
+type service struct {
+	Name string
+	*Endpoint
+}
+
+type hidden struct {
+	Port int64
+}
+
+type internal struct {
+	*hidden
+}
+
+func TestToGo(t *testing.T) {
+	item, err := read(`{:a 1 "b" [1.5 :k nil true] :c {:d "e"}}`)
+	assert.NoError(t, err)
+
+	value, err := ToGo(item)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"a": int64(1),
+		"b": []any{1.5, "k", nil, true},
+		"c": map[string]any{"d": "e"},
+	}, value)
+
+	_, err = ToGo(Hash{}.Add(KeyValue{Key: Integer{Value: 1}, Value: Nil{}}))
+	assert.EqualError(t, err, "key 1 expected keyword or string")
+}
+
+func TestFromGo(t *testing.T) {
+	u := user{Name: "ann", UserID: 7, Tags: []string{"a"}, Home: &address{City: "Oslo"}, Secret: "x"}
+
+	item, err := FromGo(u)
+	assert.NoError(t, err)
+	str, err := print(item)
+	assert.NoError(t, err)
+	assert.Equal(t, `{:name "ann" :user-id 7 :tags ("a") :home {:town "Oslo"} :extra nil}`, str)
+
+	item, err = FromGo([]any{1, 2.5, "s", false, nil, map[string]int{"k": 1}})
+	assert.NoError(t, err)
+	str, err = print(item)
+	assert.NoError(t, err)
+	assert.Equal(t, `(1 2.5 "s" false nil {:k 1})`, str)
+
+	// Fields of nil embedded structs are left out
+	item, err = FromGo(service{Name: "a"})
+	assert.NoError(t, err)
+	str, err = print(item)
+	assert.NoError(t, err)
+	assert.Equal(t, `{:name "a"}`, str)
+
+	item, err = FromGo(service{Name: "a", Endpoint: &Endpoint{Port: 80}})
+	assert.NoError(t, err)
+	str, err = print(item)
+	assert.NoError(t, err)
+	assert.Equal(t, `{:name "a" :port 80}`, str)
+
+	_, err = FromGo(map[string]any{"f": func() {}})
+	assert.EqualError(t, err, "key :f cannot convert func()")
+}
+
+func TestUnmarshal(t *testing.T) {
+	item, err := read(`{:name "bob" :user-id 3 :score 2 :tags ["x" "y"] :home {:town "Rome"} :secret "s" :extra {:n 1.5} :unknown 1}`)
+	assert.NoError(t, err)
+
+	u := user{Secret: "kept", Name: "old"}
+	assert.NoError(t, Unmarshal(item, &u))
+	assert.Equal(t, user{
+		Name:   "bob",
+		UserID: 3,
+		Score:  2,
+		Tags:   []string{"x", "y"},
+		Home:   &address{City: "Rome"},
+		Secret: "kept",
+		Extra:  map[string]any{"n": 1.5},
+	}, u)
+
+	// Round trip
+	back, err := FromGo(u)
+	assert.NoError(t, err)
+	var again user
+	assert.NoError(t, Unmarshal(back, &again))
+	again.Secret = u.Secret
+	assert.Equal(t, u, again)
+
+	item, err = read(`{:home {:town 1}}`)
+	assert.NoError(t, err)
+	assert.EqualError(t, Unmarshal(item, &u), "field :home field :town expected string, got integer")
+
+	assert.EqualError(t, Unmarshal(item, u), "unmarshal expects a non-nil pointer, got s.user")
+
+	// Nil embedded structs are allocated for their fields
+	item, err = read(`{:name "a" :port 80}`)
+	assert.NoError(t, err)
+	var svc service
+	assert.NoError(t, Unmarshal(item, &svc))
+	assert.Equal(t, service{Name: "a", Endpoint: &Endpoint{Port: 80}}, svc)
+
+	var in internal
+	assert.EqualError(t, Unmarshal(item, &in), "field :port cannot allocate embedded *s.hidden")
+}
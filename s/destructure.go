@@ -0,0 +1,200 @@
+package s
+
+import (
+	"context"
+	"fmt"
+)
+
+// bind destructures value according to pattern and stores every
+// resulting local in slots of env. Supported patterns are symbols, vectors
+// (`[a b & more :as all]`) and hashes (`{:keys [a b] :or {b 1} :as m}`),
+// which can be nested.
+func bind(ctx context.Context, pattern Item, value Item, env *Env) error {
+	switch p := pattern.(type) {
+	case Local:
+		if p.Index >= len(env.slots) {
+			return fmt.Errorf("invalid local %s", p.Name)
+		}
+		env.slots[p.Index] = value
+		return nil
+
+	case Symbol:
+		env.Define(p.Value, value)
+		return nil
+
+	case Vector:
+		return bindSeq(ctx, p, value, env)
+
+	case Hash:
+		return bindHash(ctx, p, value, env)
+
+	default:
+		return fmt.Errorf("unsupported binding form %s", printKey(pattern))
+	}
+}
+
+func seqItems(value Item) ([]Item, error) {
+	switch v := value.(type) {
+	case List:
+		return v.Value, nil
+	case Vector:
+		return v.Value, nil
+	case Nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("cannot destructure %s as a sequence", printKey(value))
+	}
+}
+
+func bindSeq(ctx context.Context, pattern Vector, value Item, env *Env) error {
+	items, err := seqItems(value)
+	if err != nil {
+		return err
+	}
+
+	pos := 0
+	for i := 0; i < len(pattern.Value); i++ {
+		p := pattern.Value[i]
+
+		if sym, ok := p.(Symbol); ok && sym.Value == "&" {
+			if i+1 >= len(pattern.Value) {
+				return fmt.Errorf("missing binding after &")
+			}
+			i++
+
+			var rest Item = Nil{}
+			if pos < len(items) {
+				rest = List{Value: items[pos:]}
+			}
+			if err := bind(ctx, pattern.Value[i], rest, env); err != nil {
+				return err
+			}
+			pos = len(items)
+			continue
+		}
+
+		if kw, ok := p.(Keyword); ok && kw.Value == "as" {
+			if i+1 >= len(pattern.Value) {
+				return fmt.Errorf("missing binding after :as")
+			}
+			i++
+
+			if err := bind(ctx, pattern.Value[i], value, env); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var item Item = Nil{}
+		if pos < len(items) {
+			item = items[pos]
+		}
+		pos++
+
+		if err := bind(ctx, p, item, env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func bindHash(ctx context.Context, pattern Hash, value Item, env *Env) error {
+	var hash Hash
+	switch v := value.(type) {
+	case Hash:
+		hash = v
+	case Nil:
+	default:
+		return fmt.Errorf("cannot destructure %s as a hash", printKey(value))
+	}
+
+	defaults := Hash{}
+	if or, ok := pattern.Get(NewKeyword("or")); ok {
+		if defaults, ok = or.(Hash); !ok {
+			return fmt.Errorf(":or expects a hash, got %s", printKey(or))
+		}
+	}
+
+	lookup := func(name string, key Item) (Item, error) {
+		if item, ok := hash.Get(key); ok {
+			return item, nil
+		}
+		if def, ok := defaults.Get(NewSymbol(name)); ok {
+			return eval(ctx, def, env)
+		}
+		return Nil{}, nil
+	}
+
+	for _, kv := range pattern.Value {
+		if kw, ok := kv.Key.(Keyword); ok {
+			switch kw.Value {
+			case "or":
+				continue
+
+			case "as":
+				if err := bind(ctx, kv.Value, value, env); err != nil {
+					return err
+				}
+				continue
+
+			case "keys", "strs", "syms":
+				names, ok := kv.Value.(Vector)
+				if !ok {
+					return fmt.Errorf(":%s expects a vector, got %s", kw.Value, printKey(kv.Value))
+				}
+
+				for _, n := range names.Value {
+					name, ok := bindingName(n)
+					if !ok {
+						return fmt.Errorf(":%s expects symbols, got %s", kw.Value, printKey(n))
+					}
+
+					var key Item
+					switch kw.Value {
+					case "keys":
+						key = NewKeyword(name)
+					case "strs":
+						key = String{Value: name}
+					default:
+						key = NewSymbol(name)
+					}
+
+					item, err := lookup(name, key)
+					if err != nil {
+						return err
+					}
+					if err := bind(ctx, n, item, env); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+		}
+
+		// {local-pattern lookup-key}
+		name, _ := bindingName(kv.Key)
+
+		item, err := lookup(name, kv.Value)
+		if err != nil {
+			return err
+		}
+		if err := bind(ctx, kv.Key, item, env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bindingName returns name of a simple binding
+func bindingName(pattern Item) (string, bool) {
+	switch p := pattern.(type) {
+	case Symbol:
+		return p.Value, true
+	case Local:
+		return p.Name, true
+	default:
+		return "", false
+	}
+}
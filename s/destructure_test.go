@@ -0,0 +1,78 @@
+package s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRep_Destructure(t *testing.T) {
+	cases := []struct {
+		input  string
+		output string
+	}{
+		// Sequential
+		{"((fn [[a b]] (+ a b)) (list 1 2))", "3"},
+		{"((fn [a & more] more) 1 2 3)", "(2 3)"},
+		{"((fn [a & more] more) 1)", "nil"},
+		{"((fn [& [a b]] (+ a b)) 4 5)", "9"},
+		{"(let {[a b & c :as all] (list 1 2 3 4)} c)", "(3 4)"},
+		{"(let {[a b & c :as all] (list 1 2 3 4)} all)", "(1 2 3 4)"},
+		{"(let {[a b c] (list 1 2)} c)", "nil"},
+		{"(let {[a [b c]] [1 [2 3]]} (+ a (* b c)))", "7"},
+		{"((fn [a :as all] (list a all)) 1)", "(1 (1))"},
+
+		// Associative
+		{`(let {{:keys [host port]} {:host "localhost" :port 8080}} port)`, "8080"},
+		{`(let {{:keys [host port] :or {port 80}} {:host "localhost"}} port)`, "80"},
+		{`(let {{:keys [host port] :or {port 80}} {:host "localhost"}} host)`, `"localhost"`},
+		{`(let {{:strs [name]} {"name" "slang"}} name)`, `"slang"`},
+		{`(let {{p :port} {:port 22}} p)`, "22"},
+		{`(let {{:keys [a] :as m} {:a 1}} m)`, "{:a 1}"},
+		{`(let {{:keys [a]} nil} a)`, "nil"},
+		{`((fn [{:keys [x y]}] (+ x y)) {:x 1 :y 2})`, "3"},
+
+		// Nested
+		{`(let {{[a b] :pair} {:pair [1 2]}} b)`, "2"},
+		{`(let {{{:keys [port]} :db} {:db {:port 5432}}} port)`, "5432"},
+		{`((fn [[{:keys [a]} & rest]] a) [{:a 7} {:a 8}])`, "7"},
+	}
+
+	for _, c := range cases {
+		res, err := Rep(c.input)
+		assert.NoError(t, err)
+		assert.Equal(t, c.output, res, "%s should return %s", c.input, c.output)
+	}
+}
+
+func TestRep_FnArity(t *testing.T) {
+	_, err := Rep("((fn [a b] a) 1)")
+	assert.EqualError(t, err, "wrong number of args (1) passed to fn")
+
+	_, err = Rep("((fn [a] a) 1 2)")
+	assert.EqualError(t, err, "wrong number of args (2) passed to fn")
+
+	_, err = Rep("((fn [a :as all] a) 1 2)")
+	assert.EqualError(t, err, "wrong number of args (2) passed to fn")
+}
+
+func TestRep_DestructureErrors(t *testing.T) {
+	_, err := Rep("((fn [{:keys [a]}] a) [1 2])")
+	assert.EqualError(t, err, "cannot destructure [1 2] as a hash")
+
+	_, err = Rep("((fn [[a]] a) {:a 1})")
+	assert.EqualError(t, err, "cannot destructure {:a 1} as a sequence")
+}
+
+func TestRep_FnCalledTwice(t *testing.T) {
+	_, err := Rep("(set inc (fn [a] (+ a 1)))")
+	assert.NoError(t, err)
+
+	res1, err1 := Rep("(inc 1)")
+	assert.NoError(t, err1)
+	assert.Equal(t, "2", res1)
+
+	res2, err2 := Rep("(inc 5)")
+	assert.NoError(t, err2)
+	assert.Equal(t, "6", res2)
+}
@@ -1,14 +1,23 @@
 package s
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	// "github.com/k0kubun/pp"
 )
 
-// Env is a structure which holds environment data
+// Env is a structure which holds environment data. It is safe to
+// define and look up names from multiple goroutines.
+//
+// Global environments keep definitions in a map, while function frames
+// created at runtime keep locals in slots resolved by the analyzer.
 type Env struct {
+	mu     sync.RWMutex
 	defs   map[string]Item
+	slots  []Item
 	parent *Env
+	ns     *Namespace
 }
 
 // NewEnv returns new environment data struct
@@ -21,46 +30,20 @@ func NewEnv() *Env {
 	return env
 }
 
-// Init sets up main environment functions which can be executed
+// Init sets up main environment functions which can be executed, that
+// is the core and math capabilities
 func (e *Env) Init() {
-	e.Define("+", Func{Value: func(args []Item) (Item, error) {
-		var result int64
-		for _, item := range args {
-			num := item.(Integer)
-			result += num.Value
-		}
-
-		return Integer{Value: result}, nil
-	}})
-
-	e.Define("-", Func{Value: func(args []Item) (Item, error) {
-		result := args[0].(Integer).Value
-		for _, item := range args[1:] {
-			result -= item.(Integer).Value
-		}
-
-		return Integer{Value: result}, nil
-	}})
-
-	e.Define("*", Func{Value: func(args []Item) (Item, error) {
-		result := args[0].(Integer).Value
-		for _, item := range args[1:] {
-			result *= item.(Integer).Value
-		}
-
-		return Integer{Value: result}, nil
-	}})
+	e.initCore()
+	e.initMath()
+}
 
-	e.Define("/", Func{Value: func(args []Item) (Item, error) {
-		result := args[0].(Integer).Value
-		for _, item := range args[1:] {
-			result /= item.(Integer).Value
+// initCore sets up functions every slang program needs
+func (e *Env) initCore() {
+	e.DefineBuiltin("list", Signature{Rest: TypeAny}, func(ctx context.Context, args []Item) (Item, error) {
+		if err := checkSize(ctx, len(args)); err != nil {
+			return nil, err
 		}
 
-		return Integer{Value: result}, nil
-	}})
-
-	e.Define("list", Func{Value: func(args []Item) (Item, error) {
 		var value []Item
 		if args == nil {
 			value = []Item{}
@@ -69,125 +52,232 @@ func (e *Env) Init() {
 		}
 
 		return List{Value: value}, nil
-	}})
+	})
 
-	e.Define("list?", Func{Value: func(args []Item) (Item, error) {
+	e.DefineBuiltin("list?", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
 		if _, ok := args[0].(List); ok {
 			return True{}, nil
 		}
 		return False{}, nil
-	}})
+	})
 
-	e.Define("empty?", Func{Value: func(args []Item) (Item, error) {
-		list := args[0].(List)
-		if len(list.Value) == 0 {
+	e.DefineBuiltin("empty?", Signature{Params: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		empty, err := args[0].(Seq).Empty(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if empty {
 			return True{}, nil
 		}
 
 		return False{}, nil
-	}})
+	})
 
-	e.Define("count", Func{Value: func(args []Item) (Item, error) {
-		if !args[0].IsList() {
-			return Integer{Value: 0}, nil
+	e.DefineBuiltin("count", Signature{Params: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		n, err := count(ctx, args[0].(Seq))
+		if err != nil {
+			return nil, err
 		}
+		return Integer{Value: int64(n)}, nil
+	})
 
-		list := args[0].(List)
-		count := int64(len(list.Value))
-		return Integer{Value: count}, nil
-	}})
-
-	// Basic cond
-
-	e.Define("=", Func{Value: func(args []Item) (Item, error) {
+	e.DefineBuiltin("=", Signature{Params: []Type{TypeAny, TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
 		left := args[0]
 		right := args[1]
 
-		if left.Equal(right).IsFalse() {
+		if seqEqual(ctx, left, right).IsFalse() {
 			return False{}, nil
 		}
 
 		return True{}, nil
-	}})
+	})
 
-	e.Define(">", Func{Value: func(args []Item) (Item, error) {
-		left := args[0].(Integer).Value
-		right := args[1].(Integer).Value
-		if left > right {
+	e.DefineBuiltin("not", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		if !Truthy(args[0]) {
 			return True{}, nil
 		}
+
 		return False{}, nil
-	}})
+	})
 
-	e.Define(">=", Func{Value: func(args []Item) (Item, error) {
-		left := args[0].(Integer).Value
-		right := args[1].(Integer).Value
-		if left >= right {
-			return True{}, nil
+	e.DefineBuiltin("read-string", Signature{Params: []Type{TypeString}}, func(ctx context.Context, args []Item) (Item, error) {
+		return NewReader().Parse(args[0].(String).Value)
+	})
+
+	// Exceptions
+
+	e.DefineBuiltin("throw", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		return nil, &Exception{Value: args[0]}
+	})
+
+	e.DefineBuiltin("ex-info", Signature{Params: []Type{TypeString}, Optional: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		var data Item = Hash{}
+		if len(args) > 1 {
+			data = args[1]
 		}
-		return False{}, nil
-	}})
 
-	e.Define("<=", Func{Value: func(args []Item) (Item, error) {
-		left := args[0].(Integer).Value
-		right := args[1].(Integer).Value
-		if left <= right {
-			return True{}, nil
+		return ExInfo{Message: args[0].(String).Value, Data: data}, nil
+	})
+
+	e.DefineBuiltin("ex-message", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		if info, ok := args[0].(ExInfo); ok {
+			return String{Value: info.Message}, nil
 		}
-		return False{}, nil
-	}})
+		return Nil{}, nil
+	})
 
-	e.Define("<", Func{Value: func(args []Item) (Item, error) {
-		left := args[0].(Integer).Value
-		right := args[1].(Integer).Value
-		if left < right {
-			return True{}, nil
+	e.DefineBuiltin("ex-data", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		if info, ok := args[0].(ExInfo); ok {
+			return info.Data, nil
 		}
+		return Nil{}, nil
+	})
+
+	e.initAtoms()
+	e.initSeqs()
+	e.initSeqLib()
+	e.initAssoc()
+	e.initTransducers()
+}
 
-		return False{}, nil
-	}})
+// arith applies integer op to integers, promoting both operands to
+// floats when either of them is a float
+func arith(left, right Item, ints func(a, b int64) int64, floats func(a, b float64) float64) Item {
+	x, xok := left.(Integer)
+	y, yok := right.(Integer)
+	if xok && yok {
+		return Integer{Value: ints(x.Value, y.Value)}
+	}
+	return Float{Value: floats(toFloat(left), toFloat(right))}
+}
 
-	e.Define("not", Func{Value: func(args []Item) (Item, error) {
-		val := args[0]
-		if val.IsFalse() {
-			return True{}, nil
+// toFloat returns value of a number as float
+func toFloat(item Item) float64 {
+	switch v := item.(type) {
+	case Integer:
+		return float64(v.Value)
+	case Float:
+		return v.Value
+	}
+	return 0
+}
+
+// initMath sets up arithmetic and comparison of integers and floats
+func (e *Env) initMath() {
+	numbers := Signature{Rest: TypeNumber}
+	atLeastOne := Signature{Params: []Type{TypeNumber}, Rest: TypeNumber}
+	pair := Signature{Params: []Type{TypeNumber, TypeNumber}}
+
+	fold := func(name string, init Item, ints func(a, b int64) int64, floats func(a, b float64) float64) {
+		sig := numbers
+		if init == nil {
+			sig = atLeastOne
 		}
 
-		return False{}, nil
-	}})
+		e.DefineBuiltin(name, sig, func(ctx context.Context, args []Item) (Item, error) {
+			result := init
+			if result == nil {
+				result, args = args[0], args[1:]
+			}
+			for _, item := range args {
+				result = arith(result, item, ints, floats)
+			}
+			return result, nil
+		})
+	}
+	fold("+", Integer{Value: 0}, func(a, b int64) int64 { return a + b }, func(a, b float64) float64 { return a + b })
+	fold("-", nil, func(a, b int64) int64 { return a - b }, func(a, b float64) float64 { return a - b })
+	fold("*", Integer{Value: 1}, func(a, b int64) int64 { return a * b }, func(a, b float64) float64 { return a * b })
+
+	e.DefineBuiltin("/", atLeastOne, func(ctx context.Context, args []Item) (Item, error) {
+		result := args[0]
+		for _, item := range args[1:] {
+			if toFloat(item) == 0 {
+				return nil, fmt.Errorf("/: division by zero")
+			}
+			result = arith(result, item,
+				func(a, b int64) int64 { return a / b },
+				func(a, b float64) float64 { return a / b })
+		}
+
+		return result, nil
+	})
+
+	compare := func(name string, ints func(left, right int64) bool, floats func(left, right float64) bool) {
+		e.DefineBuiltin(name, pair, func(ctx context.Context, args []Item) (Item, error) {
+			var ok bool
+			x, xok := args[0].(Integer)
+			y, yok := args[1].(Integer)
+			if xok && yok {
+				ok = ints(x.Value, y.Value)
+			} else {
+				ok = floats(toFloat(args[0]), toFloat(args[1]))
+			}
+
+			if ok {
+				return True{}, nil
+			}
+			return False{}, nil
+		})
+	}
+	compare(">", func(left, right int64) bool { return left > right }, func(left, right float64) bool { return left > right })
+	compare(">=", func(left, right int64) bool { return left >= right }, func(left, right float64) bool { return left >= right })
+	compare("<=", func(left, right int64) bool { return left <= right }, func(left, right float64) bool { return left <= right })
+	compare("<", func(left, right int64) bool { return left < right }, func(left, right float64) bool { return left < right })
 }
 
 // Define adds new function to an environment
 func (e *Env) Define(name string, val Item) Item {
+	e.mu.Lock()
 	e.defs[name] = val
+	e.mu.Unlock()
 	return val
 }
 
-func (e *Env) getRef(name string) (Item, error) {
-	if item, ok := e.defs[name]; ok {
-		return item, nil
+func (e *Env) getRef(name string) (Item, bool) {
+	if e.defs == nil {
+		return nil, false
 	}
-	return nil, fmt.Errorf("%s is undefined", name)
+
+	e.mu.RLock()
+	item, ok := e.defs[name]
+	e.mu.RUnlock()
+
+	if !ok && e.ns != nil {
+		return e.ns.referred(name)
+	}
+	return item, ok
 }
 
 // Get return environment function
 func (e *Env) Get(name string) (Item, error) {
-	var item Item
-	var err error
-
-	item, err = e.getRef(name)
-	if err != nil {
-		if e.parent != nil {
-			item, err = e.parent.Get(name)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, err
+	if isQualified(name) {
+		if ns, err := namespaceOf(e); err == nil {
+			return ns.get(name)
 		}
 	}
 
-	return item, nil
+	for env := e; env != nil; env = env.parent {
+		if item, ok := env.getRef(name); ok {
+			return item, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s is undefined", name)
+}
+
+// globals returns the closest environment keeping definitions by name
+func (e *Env) globals() *Env {
+	env := e
+	for env.defs == nil {
+		env = env.parent
+	}
+	return env
+}
+
+// newFrame creates function frame with given number of slots
+func (e *Env) newFrame(slots int) *Env {
+	return &Env{slots: make([]Item, slots), parent: e}
 }
 
 // NewChild creates empty child environment
@@ -1,6 +1,9 @@
 package s
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,7 +12,7 @@ import (
 func TestEnv_Define(t *testing.T) {
 	e := NewEnv()
 
-	val := Func{Value: func(items []Item) (Item, error) {
+	val := Func{Value: func(ctx context.Context, items []Item) (Item, error) {
 		return Integer{Value: 3}, nil
 	}}
 	e.Define("x", val)
@@ -20,7 +23,7 @@ func TestEnv_Define(t *testing.T) {
 func TestEnv_Get(t *testing.T) {
 	e := NewEnv()
 
-	val := Func{Value: func(items []Item) (Item, error) {
+	val := Func{Value: func(ctx context.Context, items []Item) (Item, error) {
 		return Integer{Value: 3}, nil
 	}}
 	e.Define("x", val)
@@ -37,3 +40,24 @@ func TestEnv_NewChild(t *testing.T) {
 
 	assert.Equal(t, parent, child.parent)
 }
+
+func TestEnv_Concurrent(t *testing.T) {
+	e := NewEnv()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("x%d", i)
+			child := e.NewChild()
+			e.Define(name, Integer{Value: int64(i)})
+
+			item, err := child.Get(name)
+			assert.NoError(t, err)
+			assert.Equal(t, Integer{Value: int64(i)}, item)
+		}(i)
+	}
+	wg.Wait()
+}
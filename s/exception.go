@@ -0,0 +1,137 @@
+package s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Exception is an error carrying a slang value, it is raised by `throw`
+// and can be handled with `try`/`catch`
+type Exception struct {
+	Value Item
+	Cause error
+}
+
+func (e *Exception) Error() string {
+	if info, ok := e.Value.(ExInfo); ok {
+		return info.Message
+	}
+
+	str, err := print(e.Value)
+	if err != nil {
+		return "uncaught exception"
+	}
+	return fmt.Sprintf("uncaught exception: %s", str)
+}
+
+func (e *Exception) Unwrap() error {
+	return e.Cause
+}
+
+// toException converts any error into a catchable slang exception
+func toException(err error) *Exception {
+	var ex *Exception
+	if errors.As(err, &ex) {
+		return ex
+	}
+
+	return &Exception{
+		Value: ExInfo{Message: err.Error(), Data: Hash{}},
+		Cause: err,
+	}
+}
+
+// tryForm is a parsed `(try body... (catch e handler...) (finally cleanup...))`
+type tryForm struct {
+	body []Item
+	// Binding followed by handler body, nil without catch
+	catch []Item
+	// Cleanup body, nil without finally
+	finally []Item
+}
+
+func parseTry(args []Item) (tryForm, error) {
+	var t tryForm
+
+	for _, exp := range args {
+		if list, ok := exp.(List); ok && len(list.Value) > 0 {
+			if sym, ok := list.Value[0].(Symbol); ok {
+				switch sym.Value {
+				case "catch":
+					if t.catch != nil || t.finally != nil {
+						return t, fmt.Errorf("catch must follow try body")
+					}
+					if len(list.Value) < 2 {
+						return t, fmt.Errorf("catch expects a binding")
+					}
+					t.catch = list.Value[1:]
+					continue
+
+				case "finally":
+					if t.finally != nil {
+						return t, fmt.Errorf("only one finally is allowed")
+					}
+					t.finally = list.Value[1:]
+					continue
+				}
+			}
+		}
+
+		if t.catch != nil || t.finally != nil {
+			return t, fmt.Errorf("try body must come before catch and finally")
+		}
+		t.body = append(t.body, exp)
+	}
+
+	return t, nil
+}
+
+// runTry runs body with try semantics. Handler gets the exception value
+// when body fails and cleanup always runs last, both are optional.
+// Exceeded limits and cancellation are never handled.
+func runTry(body func() (Item, error), handler func(Item) (Item, error), cleanup func() error) (Item, error) {
+	result, err := body()
+	if err != nil && handler != nil && !isFatal(err) {
+		result, err = handler(toException(err).Value)
+	}
+
+	if cleanup != nil {
+		if finErr := cleanup(); finErr != nil {
+			return nil, finErr
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// evalTry implements `(try body... (catch e handler...) (finally cleanup...))`
+func evalTry(ctx context.Context, args []Item, env *Env) (Item, error) {
+	t, err := parseTry(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var handler func(Item) (Item, error)
+	if t.catch != nil {
+		handler = func(value Item) (Item, error) {
+			if err := bind(ctx, t.catch[0], value, env); err != nil {
+				return nil, err
+			}
+			return evalDo(ctx, t.catch[1:], env)
+		}
+	}
+
+	var cleanup func() error
+	if t.finally != nil {
+		cleanup = func() error {
+			_, err := evalDo(ctx, t.finally, env)
+			return err
+		}
+	}
+
+	return runTry(func() (Item, error) { return evalDo(ctx, t.body, env) }, handler, cleanup)
+}
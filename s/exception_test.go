@@ -0,0 +1,65 @@
+package s
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRep_Try(t *testing.T) {
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{"(try 1 2)", "2"},
+		{"(try (throw 42) (catch e e))", "42"},
+		{"(try (throw 42) (catch e (+ e 1)))", "43"},
+		{`(try (throw "boom") (catch e e))`, `"boom"`},
+		{"(try (throw [1 2]) (catch [a b] (+ a b)))", "3"},
+		{`(try (throw (ex-info "bad" {:code 42})) (catch e (ex-message e)))`, `"bad"`},
+		{`(try (throw (ex-info "bad" {:code 42})) (catch e (ex-data e)))`, "{:code 42}"},
+		{`(ex-info "bad" {:code 42})`, `#error {:message "bad" :data {:code 42}}`},
+		{"(try (undefined-thing) (catch e (ex-message e)))", `"undefined-thing is undefined"`},
+		{"(try (try (throw 1) (catch e (throw (+ e 1)))) (catch e e))", "2"},
+		{"(try 1 (finally 2))", "1"},
+		{"(try (throw 1) (catch e e) (finally 2))", "1"},
+		{"(ex-message 1)", "nil"},
+	}
+
+	for _, c := range cases {
+		res, err := Rep(c.input)
+		assert.NoError(t, err)
+		assert.Equal(t, c.output, res, "%s should return %s", c.input, c.output)
+	}
+}
+
+func TestRep_Finally(t *testing.T) {
+	_, err := Rep("(try (throw 1) (finally (set finally-ran true)))")
+	assert.EqualError(t, err, "uncaught exception: 1")
+
+	res, err := Rep("finally-ran")
+	assert.NoError(t, err)
+	assert.Equal(t, "true", res)
+}
+
+func TestRep_Throw(t *testing.T) {
+	_, err := Rep(`(throw (ex-info "bad input" {}))`)
+	assert.EqualError(t, err, "bad input")
+
+	var ex *Exception
+	if assert.True(t, errors.As(err, &ex)) {
+		assert.Equal(t, ExInfo{Message: "bad input", Data: Hash{}}, ex.Value)
+	}
+}
+
+func TestToException(t *testing.T) {
+	cause := errors.New("boom")
+	ex := toException(cause)
+
+	assert.Equal(t, ExInfo{Message: "boom", Data: Hash{}}, ex.Value)
+	assert.True(t, errors.Is(ex, cause))
+
+	thrown := &Exception{Value: Integer{Value: 1}}
+	assert.Equal(t, thrown, toException(thrown))
+}
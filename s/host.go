@@ -0,0 +1,93 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Allow lets scripts use given methods and fields of values of the same
+// type as sample, e.g. Allow((*sql.DB)(nil), "Query", "Ping"). Hosts
+// hand such values to scripts as GoValue items, members which are not
+// allowed stay unreachable.
+func (in *Interpreter) Allow(sample any, members ...string) {
+	t := reflect.TypeOf(sample)
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	allowed := in.hosts[t]
+	if allowed == nil {
+		allowed = make(map[string]bool)
+		in.hosts[t] = allowed
+	}
+	for _, member := range members {
+		allowed[member] = true
+	}
+}
+
+// allowed returns true if member of type t can be used by scripts
+func (in *Interpreter) allowed(t reflect.Type, member string) bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.hosts[t][member]
+}
+
+// fromHost converts result of a member, values of registered types are
+// wrapped so they can be used further
+func (in *Interpreter) fromHost(v reflect.Value) (Item, error) {
+	if v.IsValid() && v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.IsValid() && !(v.Kind() == reflect.Pointer && v.IsNil()) {
+		in.mu.Lock()
+		_, ok := in.hosts[v.Type()]
+		in.mu.Unlock()
+
+		if ok {
+			return GoValue{Value: v.Interface()}, nil
+		}
+	}
+	return fromGoValue(v)
+}
+
+// initHost sets up `.` calling methods and reading fields of host values,
+// `(. obj Member args...)` is turned into `(. obj "Member" args...)` by
+// the analyzer
+func (in *Interpreter) initHost() {
+	sig := Signature{Params: []Type{TypeGoValue, TypeString}, Rest: TypeAny}
+	in.env.DefineBuiltin(".", sig, func(ctx context.Context, args []Item) (Item, error) {
+		v := reflect.ValueOf(args[0].(GoValue).Value)
+		member := args[1].(String).Value
+		if !v.IsValid() {
+			return nil, fmt.Errorf(".: cannot use %s of nil", member)
+		}
+
+		t := v.Type()
+		if !in.allowed(t, member) {
+			return nil, fmt.Errorf(".: %s of %s is not allowed", member, t)
+		}
+
+		if method := v.MethodByName(member); method.IsValid() {
+			return goCall(fmt.Sprintf("%s.%s", t, member), method, in.fromHost)(ctx, args[2:])
+		}
+
+		for v.Kind() == reflect.Pointer && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Struct {
+			if f, ok := v.Type().FieldByName(member); ok && f.IsExported() {
+				if len(args) > 2 {
+					return nil, fmt.Errorf(".: field %s of %s takes no arguments", member, t)
+				}
+				field, err := v.FieldByIndexErr(f.Index)
+				if err != nil {
+					return nil, fmt.Errorf(".: %w", err)
+				}
+				return in.fromHost(field)
+			}
+		}
+
+		return nil, fmt.Errorf(".: %s has no member %s", t, member)
+	})
+}
@@ -0,0 +1,92 @@
+package s
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type account struct {
+	Owner   string
+	Balance int
+	Parent  *account
+	secret  string
+}
+
+func (a *account) Deposit(n int) (int, error) {
+	if n <= 0 {
+		return 0, errors.New("invalid amount")
+	}
+	a.Balance += n
+	return a.Balance, nil
+}
+
+func (a *account) Child(owner string) *account {
+	return &account{Owner: owner, Parent: a}
+}
+
+func (a *account) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestGoValue(t *testing.T) {
+	for name, backend := range backends {
+		acc := &account{Owner: "ann", secret: "s"}
+
+		in := NewInterpreter(Options{Backend: backend})
+		in.Allow(acc, "Deposit", "Child", "Owner", "Balance", "Parent", "secret")
+		in.Env().Define("acc", GoValue{Value: acc})
+
+		cases := []struct {
+			input  string
+			output string
+			err    string
+		}{
+			{input: "acc", output: "#<GoValue *s.account>"},
+			{input: "(. acc Deposit 5)", output: "5"},
+			{input: "(let [a acc] (. a Deposit 2) (. a Balance))", output: "7"},
+			{input: "(. acc Owner)", output: `"ann"`},
+			{input: "(. (. acc Child \"bob\") Parent)", output: "#<GoValue *s.account>"},
+			{input: "(. (. (. acc Child \"bob\") Parent) Owner)", output: `"ann"`},
+			{input: "(= acc (. (. acc Child \"bob\") Parent))", output: "true"},
+			{input: "(. acc Deposit 0)", err: "invalid amount"},
+			{input: "(. acc Deposit :a)", err: "*s.account.Deposit: argument 1 expected int, got keyword"},
+			{input: "(. acc Close)", err: ".: Close of *s.account is not allowed"},
+			{input: "(. acc secret)", err: ".: *s.account has no member secret"},
+			{input: "(. acc Owner 1)", err: ".: field Owner of *s.account takes no arguments"},
+			{input: "(. 1 Owner)", err: ".: argument 1 expected GoValue, got integer"},
+			{input: "(. acc)", err: ". expects an object and a member name"},
+			{input: "(. acc :Owner)", err: ". expects a member name, got :Owner"},
+		}
+
+		for _, c := range cases {
+			res, err := in.Rep(c.input)
+			if c.err != "" {
+				assert.EqualError(t, err, c.err, "%s: %s", name, c.input)
+				continue
+			}
+			assert.NoError(t, err, "%s: %s", name, c.input)
+			assert.Equal(t, c.output, res, "%s: %s", name, c.input)
+		}
+		assert.Equal(t, 7, acc.Balance, name)
+	}
+
+	// Types nobody allowed are not reachable at all
+	in := NewInterpreter(Options{})
+	in.Env().Define("acc", GoValue{Value: &account{}})
+	_, err := in.EvalString("(. acc Owner)")
+	assert.EqualError(t, err, ".: Owner of *s.account is not allowed")
+}
+
+func TestGoValue_Arguments(t *testing.T) {
+	in := NewInterpreter(Options{})
+	acc := &account{Balance: 1}
+	in.Env().Define("acc", GoValue{Value: acc})
+	assert.NoError(t, in.Env().DefineGo("balance", func(a *account) int { return a.Balance }))
+
+	res, err := in.Rep("(balance acc)")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", res)
+}
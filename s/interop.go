@@ -0,0 +1,120 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// GoFunc wraps any Go function so slang code can call it. Arguments are
+// converted to parameter types as by Unmarshal and results back to items
+// as by FromGo.
+//
+// A leading context.Context parameter receives context of the call. A
+// trailing error result is returned as an error, remaining results are
+// returned as a single item, a list when there is more than one, or nil.
+func GoFunc(name string, fn any) (Func, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return Func{}, fmt.Errorf("%s: expected a function, got %T", name, fn)
+	}
+	return Func{Value: goCall(name, v, fromGoValue)}, nil
+}
+
+// goCall returns function calling Go function v, its results are
+// converted to items by convert
+func goCall(name string, v reflect.Value, convert func(reflect.Value) (Item, error)) ItemFunc {
+	t := v.Type()
+
+	var params []reflect.Type
+	for i := 0; i < t.NumIn(); i++ {
+		params = append(params, t.In(i))
+	}
+
+	withCtx := len(params) > 0 && params[0] == contextType
+	if withCtx {
+		params = params[1:]
+	}
+
+	sig := Signature{Params: make([]Type, len(params))}
+	for i := range sig.Params {
+		sig.Params[i] = TypeAny
+	}
+	if t.IsVariadic() {
+		sig.Params = sig.Params[:len(params)-1]
+		sig.Rest = TypeAny
+	}
+
+	results := t.NumOut()
+	withErr := results > 0 && t.Out(results-1) == errorType
+	if withErr {
+		results--
+	}
+
+	return func(ctx context.Context, args []Item) (result Item, err error) {
+		if err := sig.check(name, args); err != nil {
+			return nil, err
+		}
+
+		in := make([]reflect.Value, 0, len(args)+1)
+		if withCtx {
+			in = append(in, reflect.ValueOf(ctx))
+		}
+		for i, arg := range args {
+			pt := params[min(i, len(params)-1)]
+			if t.IsVariadic() && i >= len(params)-1 {
+				pt = pt.Elem()
+			}
+
+			value, err := toGoValue(arg, pt)
+			if err != nil {
+				return nil, fmt.Errorf("%s: argument %d %w", name, i+1, err)
+			}
+			in = append(in, value)
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				result, err = nil, fmt.Errorf("%s: %v", name, r)
+			}
+		}()
+		out := v.Call(in)
+
+		if withErr {
+			if err, _ := out[results].Interface().(error); err != nil {
+				return nil, err
+			}
+		}
+
+		items := make([]Item, results)
+		for i := range items {
+			if items[i], err = convert(out[i]); err != nil {
+				return nil, fmt.Errorf("%s: result %d %w", name, i+1, err)
+			}
+		}
+
+		switch results {
+		case 0:
+			return Nil{}, nil
+		case 1:
+			return items[0], nil
+		default:
+			return List{Value: items}, nil
+		}
+	}
+}
+
+// DefineGo defines a Go function under given name, see GoFunc
+func (e *Env) DefineGo(name string, fn any) error {
+	f, err := GoFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	e.Define(name, f)
+	return nil
+}
@@ -0,0 +1,100 @@
+package s
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type point struct {
+	X, Y    int
+	UserID  string
+	private int
+}
+
+func TestGoFunc(t *testing.T) {
+	in := NewInterpreter(Options{})
+	env := in.Env()
+
+	assert.NoError(t, env.DefineGo("repeat-str", strings.Repeat))
+	assert.NoError(t, env.DefineGo("contains?", func(s string, n int) (bool, error) {
+		if n < 0 {
+			return false, errors.New("negative")
+		}
+		return strings.Contains(s, strings.Repeat("a", n)), nil
+	}))
+	assert.NoError(t, env.DefineGo("sum", func(base int8, nums ...int) int {
+		total := int(base)
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	}))
+	assert.NoError(t, env.DefineGo("keys", func(m map[string]int) []string {
+		var keys []string
+		for k := range m {
+			keys = append(keys, k)
+		}
+		return keys
+	}))
+	assert.NoError(t, env.DefineGo("move", func(p point, d [2]int) *point {
+		return &point{X: p.X + d[0], Y: p.Y + d[1], UserID: p.UserID}
+	}))
+	assert.NoError(t, env.DefineGo("describe", func(v any) string {
+		switch v.(type) {
+		case []any:
+			return "seq"
+		case map[string]any:
+			return "map"
+		case nil:
+			return "nil"
+		}
+		return "value"
+	}))
+	assert.NoError(t, env.DefineGo("ctx?", func(ctx context.Context) bool { return ctx != nil }))
+	assert.NoError(t, env.DefineGo("pair", func() (int, string) { return 1, "a" }))
+	assert.NoError(t, env.DefineGo("first-item", func(items []Item) Item { return items[0] }))
+	assert.NoError(t, env.DefineGo("boom", func() { panic("boom") }))
+
+	cases := []struct {
+		input  string
+		output string
+		err    string
+	}{
+		{input: `(repeat-str "ab" 2)`, output: `"abab"`},
+		{input: `(contains? "caab" 2)`, output: "true"},
+		{input: `(contains? "caab" (- 0 1))`, err: "negative"},
+		{input: `(contains? "caab" "2")`, err: "contains?: argument 2 expected int, got string"},
+		{input: `(contains? "caab")`, err: "contains?: expected 2 arguments, got 1"},
+		{input: `(sum 1)`, output: "1"},
+		{input: `(sum 1 2 3)`, output: "6"},
+		{input: `(sum 1 2 :a)`, err: "sum: argument 3 expected int, got keyword"},
+		{input: `(sum 300)`, err: "sum: argument 1 expected int8, got 300 out of range"},
+		{input: `(keys {:a 1})`, output: `("a")`},
+		{input: `(keys {:a "1"})`, err: "keys: argument 1 key :a expected int, got string"},
+		{input: `(move {:x 1 :y 2 :user-id "u"} [1 1])`, output: `{:x 2 :y 3 :user-id "u"}`},
+		{input: `(move {:x :a} [1 1])`, err: "move: argument 1 field :x expected int, got keyword"},
+		{input: `(move {} [1])`, err: "move: argument 2 expected [2]int, got 1 items"},
+		{input: `(list (describe [1]) (describe {:a 1}) (describe nil) (describe 1))`, output: `("seq" "map" "nil" "value")`},
+		{input: `(ctx?)`, output: "true"},
+		{input: `(pair)`, output: `(1 "a")`},
+		{input: `(first-item [:a])`, output: ":a"},
+		{input: `(boom)`, err: "boom: boom"},
+	}
+
+	for _, c := range cases {
+		res, err := in.Rep(c.input)
+		if c.err != "" {
+			assert.EqualError(t, err, c.err, c.input)
+			continue
+		}
+		assert.NoError(t, err, c.input)
+		assert.Equal(t, c.output, res, c.input)
+	}
+
+	_, err := GoFunc("bad", 1)
+	assert.EqualError(t, err, "bad: expected a function, got int")
+}
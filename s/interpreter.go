@@ -0,0 +1,273 @@
+package s
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Options configures an Interpreter
+type Options struct {
+	// Stdout receives output of `print`, `println` and `prn`, os.Stdout by default
+	Stdout io.Writer
+	// Stderr receives output of `eprintln`, os.Stderr by default
+	Stderr io.Writer
+	// Path lists directories `require` looks up modules in
+	Path []string
+	// Backend executes analyzed code, BackendTree by default
+	Backend Backend
+	// Limits bound resources of every top level evaluation
+	Limits Limits
+	// Capabilities lists builtins available to code, AllCapabilities when
+	// nil. Use Sandbox to run untrusted code.
+	Capabilities []Capability
+}
+
+// Interpreter is an isolated slang runtime with its own root environment
+type Interpreter struct {
+	env     *Env
+	stdout  io.Writer
+	stderr  io.Writer
+	path    []string
+	backend Backend
+	limits  Limits
+
+	mu         sync.Mutex
+	current    *Namespace
+	namespaces map[string]*Namespace
+	loads      map[string]*moduleLoad
+	hosts      map[reflect.Type]map[string]bool
+}
+
+// NewInterpreter returns interpreter with builtins set up
+func NewInterpreter(opts Options) *Interpreter {
+	in := &Interpreter{
+		env:        NewEnv(),
+		stdout:     opts.Stdout,
+		stderr:     opts.Stderr,
+		path:       opts.Path,
+		backend:    opts.Backend,
+		limits:     opts.Limits,
+		namespaces: make(map[string]*Namespace),
+		loads:      make(map[string]*moduleLoad),
+		hosts:      make(map[reflect.Type]map[string]bool),
+	}
+	if in.stdout == nil {
+		in.stdout = os.Stdout
+	}
+	if in.stderr == nil {
+		in.stderr = os.Stderr
+	}
+
+	caps := opts.Capabilities
+	if caps == nil {
+		caps = AllCapabilities
+	}
+	in.grant(caps)
+	in.initEval()
+	in.initHost()
+
+	in.current = in.namespace(DefaultNamespace)
+	in.markLoaded(DefaultNamespace)
+
+	return in
+}
+
+// Env returns root environment of the interpreter shared by all
+// namespaces, so embedders can define their own functions
+func (in *Interpreter) Env() *Env {
+	return in.env
+}
+
+// Namespace returns current namespace
+func (in *Interpreter) Namespace() *Namespace {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.current
+}
+
+// Eval executes given form in the current namespace
+func (in *Interpreter) Eval(item Item) (Item, error) {
+	return in.EvalContext(context.Background(), item)
+}
+
+// EvalContext executes given form in the current namespace, evaluation
+// stops with an error once ctx is done
+func (in *Interpreter) EvalContext(ctx context.Context, item Item) (Item, error) {
+	ctx, cancel := startBudget(ctx, in.limits)
+	defer cancel()
+
+	result, ns, err := in.evalIn(ctx, in.Namespace(), item)
+	if err != nil {
+		return nil, err
+	}
+
+	in.mu.Lock()
+	in.current = ns
+	in.mu.Unlock()
+
+	return result, nil
+}
+
+// EvalString reads all forms from code and executes them in order,
+// returning result of the last one
+func (in *Interpreter) EvalString(code string) (Item, error) {
+	return in.EvalStringContext(context.Background(), code)
+}
+
+// EvalStringContext is EvalString which stops once ctx is done
+func (in *Interpreter) EvalStringContext(ctx context.Context, code string) (Item, error) {
+	forms, err := NewReader().ParseAll(code)
+	if err != nil {
+		return nil, err
+	}
+
+	// Limits apply to the code as a whole
+	ctx, cancel := startBudget(ctx, in.limits)
+	defer cancel()
+
+	var result Item = Nil{}
+	for _, form := range forms {
+		result, err = in.EvalContext(ctx, form)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// LoadFile executes all forms from given file in the current namespace.
+// Namespace switches made by the file don't outlive the load.
+func (in *Interpreter) LoadFile(path string) (Item, error) {
+	ctx, cancel := startBudget(context.Background(), in.limits)
+	defer cancel()
+
+	result, _, err := in.loadFile(ctx, path, in.Namespace())
+	return result, err
+}
+
+// Rep reads a single form, executes it and returns printed result
+func (in *Interpreter) Rep(input string) (string, error) {
+	ast, err := read(input)
+	if err != nil {
+		return "", err
+	}
+
+	// Lazy sequences are printed within limits of the evaluation
+	ctx, cancel := startBudget(context.Background(), in.limits)
+	defer cancel()
+
+	exp, err := in.EvalContext(ctx, ast)
+	if err != nil {
+		return "", err
+	}
+
+	return printContext(ctx, exp)
+}
+
+func (in *Interpreter) loadFile(ctx context.Context, path string, ns *Namespace) (Item, *Namespace, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var forms []Item
+	if isModule(code) {
+		m, err := ReadModule(bytes.NewReader(code))
+		if err != nil {
+			return nil, nil, err
+		}
+		forms = m.Forms
+	} else if forms, err = NewFileReader(path).ParseAll(string(code)); err != nil {
+		return nil, nil, err
+	}
+
+	var result Item = Nil{}
+	for _, form := range forms {
+		result, ns, err = in.evalIn(ctx, ns, form)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return result, ns, nil
+}
+
+// evalIn executes form or compiled chunk in given namespace and returns
+// namespace the following forms should run in, which differs after top
+// level `ns`
+func (in *Interpreter) evalIn(ctx context.Context, ns *Namespace, form Item) (Item, *Namespace, error) {
+	if isNs(form) {
+		next, err := evalNs(ctx, form.(List).Value[1:], ns.env)
+		if err != nil {
+			return nil, nil, err
+		}
+		return Nil{}, next, nil
+	}
+
+	var result Item
+	var err error
+	if chunk, ok := form.(*Chunk); ok {
+		result, err = chunk.Run(ctx, ns.env)
+	} else {
+		result, err = Eval(ctx, form, ns.env)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, ns, nil
+}
+
+// initIO sets up functions writing to interpreter outputs
+func (in *Interpreter) initIO() {
+	write := func(w io.Writer, readable bool, newline bool) ItemFunc {
+		return func(ctx context.Context, args []Item) (Item, error) {
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				str, err := display(ctx, arg, readable)
+				if err != nil {
+					return nil, err
+				}
+				parts[i] = str
+			}
+
+			output := strings.Join(parts, " ")
+			if newline {
+				output += "\n"
+			}
+			if _, err := io.WriteString(w, output); err != nil {
+				return nil, err
+			}
+
+			return Nil{}, nil
+		}
+	}
+
+	values := Signature{Rest: TypeAny}
+	in.env.DefineBuiltin("print", values, write(in.stdout, false, false))
+	in.env.DefineBuiltin("println", values, write(in.stdout, false, true))
+	in.env.DefineBuiltin("prn", values, write(in.stdout, true, true))
+	in.env.DefineBuiltin("eprintln", values, write(in.stderr, false, true))
+}
+
+// initEval sets up functions evaluating code at runtime, they run in
+// the current namespace of the interpreter
+func (in *Interpreter) initEval() {
+	in.env.DefineBuiltin("eval", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		return Eval(ctx, args[0], in.Namespace().env)
+	})
+}
+
+// display returns printed item, strings are left unquoted unless readable
+func display(ctx context.Context, item Item, readable bool) (string, error) {
+	if str, ok := item.(String); ok && !readable {
+		return str.Value, nil
+	}
+
+	return printContext(ctx, item)
+}
@@ -0,0 +1,137 @@
+package s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpreter_Isolated(t *testing.T) {
+	first := NewInterpreter(Options{})
+	second := NewInterpreter(Options{})
+
+	_, err := first.EvalString("(set shared 1)")
+	assert.NoError(t, err)
+
+	_, err = second.EvalString("shared")
+	assert.EqualError(t, err, "shared is undefined")
+}
+
+func TestInterpreter_OverrideBuiltin(t *testing.T) {
+	in := NewInterpreter(Options{})
+
+	_, err := in.EvalString("(set + (fn [a b] (* a b)))")
+	assert.NoError(t, err)
+
+	res, err := in.Rep("(+ 3 4)")
+	assert.NoError(t, err)
+	assert.Equal(t, "12", res)
+}
+
+func TestInterpreter_EvalString(t *testing.T) {
+	in := NewInterpreter(Options{})
+
+	res, err := in.EvalString("(set a 1) ; comment\n(set b (+ a 1))\n(+ a b)")
+	assert.NoError(t, err)
+	assert.Equal(t, Integer{Value: 3}, res)
+
+	res, err = in.EvalString("")
+	assert.NoError(t, err)
+	assert.Equal(t, Nil{}, res)
+}
+
+func TestInterpreter_Output(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	in := NewInterpreter(Options{Stdout: &stdout, Stderr: &stderr})
+
+	_, err := in.EvalString(`(println "a" 1 :b) (prn "a" 1) (print "x") (eprintln "oops")`)
+	assert.NoError(t, err)
+	assert.Equal(t, "a 1 :b\n\"a\" 1\nx", stdout.String())
+	assert.Equal(t, "oops\n", stderr.String())
+}
+
+func TestInterpreter_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.slang")
+	err := os.WriteFile(path, []byte("(set double (fn [x] (* 2 x)))\n(double 4)\n"), 0644)
+	assert.NoError(t, err)
+
+	in := NewInterpreter(Options{})
+	res, err := in.LoadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Integer{Value: 8}, res)
+
+	res, err = in.Eval(List{Value: []Item{NewSymbol("double"), Integer{Value: 5}}})
+	assert.NoError(t, err)
+	assert.Equal(t, Integer{Value: 10}, res)
+
+	_, err = in.LoadFile(filepath.Join(t.TempDir(), "missing.slang"))
+	assert.Error(t, err)
+}
+
+// Run with `go test -race` to detect unsynchronised access
+func TestInterpreter_Parallel(t *testing.T) {
+	in := NewInterpreter(Options{})
+	_, err := in.EvalString("(set fact (fn [n] (if (<= n 1) 1 (* n (fact (- n 1))))))")
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+				code := fmt.Sprintf("(set r%d (let [[a b] [%d %d]] (+ (fact 5) a b)))", i, i, j)
+				res, err := in.EvalString(code)
+				assert.NoError(t, err)
+				assert.Equal(t, Integer{Value: int64(120 + i + j)}, res)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestInterpreter_EvalBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.slang")
+	err := os.WriteFile(lib, []byte("(set lib-value 42)\n(set lib-fail (fn []\n  (throw 1)))"), 0644)
+	assert.NoError(t, err)
+
+	in := NewInterpreter(Options{})
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{`(read-string "(+ 1 2)")`, "(+ 1 2)"},
+		{`(read-string ":kw")`, ":kw"},
+		{`(eval (read-string "(+ 1 2)"))`, "3"},
+		{`(eval (list + 1 2))`, "3"},
+		{`(eval 7)`, "7"},
+		{`(eval (read-string "(set evaluated 1)"))`, "1"},
+		{"evaluated", "1"},
+		{fmt.Sprintf("(load-file %q)", lib), "function"},
+		{"lib-value", "42"},
+	}
+
+	for _, c := range cases {
+		res, err := in.Rep(c.input)
+		assert.NoError(t, err)
+		assert.Equal(t, c.output, res, "%s should return %s", c.input, c.output)
+	}
+
+	_, err = in.Rep(`(read-string "(+ 1")`)
+	assert.EqualError(t, err, "unexpected EOF while reading")
+
+	// Errors inside loaded files point to the file
+	_, err = in.Rep("(lib-fail)")
+	var evalErr *EvalError
+	if assert.True(t, errors.As(err, &evalErr)) {
+		assert.Equal(t, Frame{Name: "throw", Position: Position{File: lib, Line: 3, Column: 3}}, evalErr.Stack[0])
+	}
+}
@@ -0,0 +1,147 @@
+package s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxDepth is used when Limits.MaxDepth is zero, so that runaway
+// recursion fails before it overflows the Go stack
+const DefaultMaxDepth = 10000
+
+// Limits bound resources of a single top level evaluation, zero values
+// mean no limit
+type Limits struct {
+	// MaxSteps limits number of function calls
+	MaxSteps int64
+	// MaxDepth limits depth of nested calls of slang functions,
+	// DefaultMaxDepth when zero
+	MaxDepth int
+	// Timeout limits wall time of the evaluation
+	Timeout time.Duration
+	// MaxCollection limits number of items in collections built at runtime
+	MaxCollection int
+}
+
+// LimitExceeded is returned when evaluation goes over one of Limits. It
+// cannot be caught by `try`.
+type LimitExceeded struct {
+	// Limit is one of "steps", "depth", "time" or "collection"
+	Limit string
+	// Max is the configured limit
+	Max any
+}
+
+func (e *LimitExceeded) Error() string {
+	return fmt.Sprintf("%s limit of %v exceeded", e.Limit, e.Max)
+}
+
+// budget tracks resources used by one evaluation, it is shared by all
+// calls made from it through the context
+type budget struct {
+	limits Limits
+	steps  *atomic.Int64
+	depth  atomic.Int64
+}
+
+type budgetKey struct{}
+
+// startBudget starts accounting of a top level evaluation unless ctx
+// already belongs to one, the returned cancel function must be called
+// once it is done
+func startBudget(ctx context.Context, limits Limits) (context.Context, context.CancelFunc) {
+	if budgetOf(ctx) != nil {
+		return ctx, func() {}
+	}
+
+	if limits.MaxDepth == 0 {
+		limits.MaxDepth = DefaultMaxDepth
+	}
+
+	b := &budget{limits: limits, steps: new(atomic.Int64)}
+	ctx = context.WithValue(ctx, budgetKey{}, b)
+
+	if limits.Timeout > 0 {
+		return context.WithTimeoutCause(ctx, limits.Timeout, &LimitExceeded{Limit: "time", Max: limits.Timeout})
+	}
+	return ctx, func() {}
+}
+
+// limitsOf returns limits of the interpreter env belongs to
+func limitsOf(env *Env) Limits {
+	if ns, err := namespaceOf(env); err == nil {
+		return ns.in.limits
+	}
+	return Limits{}
+}
+
+// forkBudget returns context for a goroutine started by the evaluation,
+// it shares steps of the evaluation but nests calls from zero depth
+func forkBudget(ctx context.Context) context.Context {
+	b := budgetOf(ctx)
+	if b == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, budgetKey{}, &budget{limits: b.limits, steps: b.steps})
+}
+
+func budgetOf(ctx context.Context) *budget {
+	b, _ := ctx.Value(budgetKey{}).(*budget)
+	return b
+}
+
+// step accounts a function call and reports cancellation of ctx
+func step(ctx context.Context) error {
+	if ctx.Done() != nil && ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	b := budgetOf(ctx)
+	if b == nil || b.limits.MaxSteps == 0 {
+		return nil
+	}
+
+	if b.steps.Add(1) > b.limits.MaxSteps {
+		return &LimitExceeded{Limit: "steps", Max: b.limits.MaxSteps}
+	}
+	return nil
+}
+
+// enter accounts call of a slang function, leave must follow on success
+func (b *budget) enter() error {
+	if b == nil {
+		return nil
+	}
+
+	if b.depth.Add(1) > int64(b.limits.MaxDepth) {
+		b.depth.Add(-1)
+		return &LimitExceeded{Limit: "depth", Max: b.limits.MaxDepth}
+	}
+	return nil
+}
+
+func (b *budget) leave() {
+	if b != nil {
+		b.depth.Add(-1)
+	}
+}
+
+// checkSize fails when a collection of n items is over the limit
+func checkSize(ctx context.Context, n int) error {
+	b := budgetOf(ctx)
+	if b == nil || b.limits.MaxCollection == 0 || n <= b.limits.MaxCollection {
+		return nil
+	}
+	return &LimitExceeded{Limit: "collection", Max: b.limits.MaxCollection}
+}
+
+// isFatal reports errors which `try` must not catch, they stop the
+// whole evaluation
+func isFatal(err error) bool {
+	var limit *LimitExceeded
+	return errors.As(err, &limit) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
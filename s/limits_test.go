@@ -0,0 +1,81 @@
+package s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		limits Limits
+		code   string
+		err    string
+	}{
+		{Limits{MaxSteps: 100}, "(set loop (fn [n] (loop (+ n 1)))) (loop 0)", "steps limit of 100 exceeded"},
+		{Limits{MaxSteps: 100}, "(set loop (fn [n] (loop (+ n 1)))) (try (loop 0) (catch e :caught))", "steps limit of 100 exceeded"},
+		{Limits{MaxDepth: 50}, "(set loop (fn [n] (loop (+ n 1)))) (loop 0)", "depth limit of 50 exceeded"},
+		{Limits{}, "(set loop (fn [n] (loop (+ n 1)))) (loop 0)", "depth limit of 10000 exceeded"},
+		{Limits{Timeout: 20 * time.Millisecond}, "(set fib (fn [n] (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2)))))) (fib 40)", "time limit of 20ms exceeded"},
+		{Limits{MaxCollection: 3}, "(list 1 2 3 4)", "collection limit of 3 exceeded"},
+	}
+
+	for name, backend := range backends {
+		for _, c := range cases {
+			in := NewInterpreter(Options{Backend: backend, Limits: c.limits})
+			_, err := in.EvalString(c.code)
+
+			var limit *LimitExceeded
+			if assert.True(t, errors.As(err, &limit), "%s: %s", name, c.code) {
+				assert.EqualError(t, limit, c.err, "%s: %s", name, c.code)
+			}
+		}
+	}
+}
+
+func TestLimits_Passing(t *testing.T) {
+	in := NewInterpreter(Options{Limits: Limits{MaxSteps: 1000, MaxDepth: 20, MaxCollection: 3}})
+
+	res, err := in.EvalString("(set f (fn [n] (if (= n 0) (list 1 2 3) (f (- n 1))))) (f 10)")
+	assert.NoError(t, err)
+	assert.Equal(t, List{Value: []Item{Integer{Value: 1}, Integer{Value: 2}, Integer{Value: 3}}}, res)
+
+	// Every top level evaluation gets a new budget
+	for i := 0; i < 10; i++ {
+		_, err := in.Rep("(f 10)")
+		assert.NoError(t, err)
+	}
+}
+
+func TestLimits_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	in := NewInterpreter(Options{})
+	_, err := in.EvalStringContext(ctx, "(+ 1 2)")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var limit *LimitExceeded
+	assert.False(t, errors.As(err, &limit))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = in.EvalStringContext(ctx, "(set fib (fn [n] (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2)))))) (fib 40)")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLimits_Trace(t *testing.T) {
+	in := NewInterpreter(Options{Limits: Limits{MaxDepth: 100}})
+	_, err := in.EvalString("(set loop (fn [n] (loop (+ n 1)))) (loop 0)")
+
+	var evalErr *EvalError
+	if assert.True(t, errors.As(err, &evalErr)) {
+		assert.Len(t, evalErr.Stack, maxFrames)
+		// 100 entered calls and the one refused
+		assert.Equal(t, 101-maxFrames, evalErr.Dropped)
+		assert.Contains(t, evalErr.Trace(), "  ... 37 more")
+	}
+}
@@ -0,0 +1,545 @@
+package s
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// moduleMagic starts every compiled module, the last byte is the version
+var moduleMagic = []byte("slangc\x00\x01")
+
+// Module is a compiled source file which can be stored and loaded
+// without parsing it again. Top level `ns` forms are kept as they are,
+// every other form is compiled into a Chunk.
+type Module struct {
+	Forms []Item
+}
+
+// CompileModule compiles every form of given code
+func CompileModule(code string, file string) (*Module, error) {
+	forms, err := NewFileReader(file).ParseAll(code)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Module{}
+	for _, form := range forms {
+		if isNs(form) {
+			m.Forms = append(m.Forms, form)
+			continue
+		}
+
+		chunk, err := Compile(form)
+		if err != nil {
+			return nil, err
+		}
+		m.Forms = append(m.Forms, chunk)
+	}
+
+	return m, nil
+}
+
+// CompileFile compiles source file src into module file dst
+func CompileFile(src string, dst string) error {
+	code, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	m, err := CompileModule(string(code), src)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := m.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Disassemble writes readable listing of every compiled form
+func (m *Module) Disassemble(w io.Writer) error {
+	for i, form := range m.Forms {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+
+		chunk, ok := form.(*Chunk)
+		if !ok {
+			str, err := print(form)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(w, str); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := chunk.Disassemble(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTo writes binary form of the module
+func (m *Module) WriteTo(w io.Writer) (int64, error) {
+	enc := &encoder{w: bufio.NewWriter(w)}
+	enc.bytes(moduleMagic)
+	enc.items(m.Forms)
+	if enc.err == nil {
+		enc.err = enc.w.Flush()
+	}
+	return enc.n, enc.err
+}
+
+// ReadModule reads module written by Module.WriteTo
+func ReadModule(r io.Reader) (*Module, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !isModule(data) {
+		return nil, fmt.Errorf("not a compiled slang module")
+	}
+
+	dec := &decoder{r: bytes.NewReader(data[len(moduleMagic):])}
+	forms, err := dec.items()
+	if err != nil {
+		return nil, fmt.Errorf("corrupted module: %w", err)
+	}
+
+	for _, form := range forms {
+		chunk, ok := form.(*Chunk)
+		if !ok {
+			continue
+		}
+
+		// Top level chunks get a frame only when they need slots
+		var frames []int
+		if chunk.Slots > 0 {
+			frames = []int{chunk.Slots}
+		}
+		if err := chunk.verify(frames); err != nil {
+			return nil, fmt.Errorf("corrupted module: %w", err)
+		}
+	}
+	return &Module{Forms: forms}, nil
+}
+
+// isModule returns true if data holds a compiled module
+func isModule(data []byte) bool {
+	return bytes.HasPrefix(data, moduleMagic)
+}
+
+// isNs returns true for top level `(ns ...)` form
+func isNs(form Item) bool {
+	if list, ok := form.(List); ok && len(list.Value) > 0 {
+		if sym, ok := list.Value[0].(Symbol); ok && sym.Value == "ns" {
+			return true
+		}
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Tags of encoded items
+const (
+	tagNil byte = iota
+	tagTrue
+	tagFalse
+	tagInteger
+	tagString
+	tagSymbol
+	tagKeyword
+	tagList
+	tagVector
+	tagHash
+	tagLocal
+	tagLambda
+	tagChunk
+	tagFloat
+)
+
+// maxLength limits numbers read from a module, counts of encoded things
+// are limited by the input instead
+const maxLength = 1 << 24
+
+type encoder struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (enc *encoder) bytes(b []byte) {
+	if enc.err != nil {
+		return
+	}
+	n, err := enc.w.Write(b)
+	enc.n += int64(n)
+	enc.err = err
+}
+
+func (enc *encoder) uint(v int) {
+	enc.bytes(binary.AppendUvarint(nil, uint64(v)))
+}
+
+func (enc *encoder) int(v int64) {
+	enc.bytes(binary.AppendVarint(nil, v))
+}
+
+func (enc *encoder) bool(v bool) {
+	if v {
+		enc.uint(1)
+	} else {
+		enc.uint(0)
+	}
+}
+
+func (enc *encoder) string(s string) {
+	enc.uint(len(s))
+	enc.bytes([]byte(s))
+}
+
+func (enc *encoder) items(items []Item) {
+	enc.uint(len(items))
+	for _, item := range items {
+		enc.item(item)
+	}
+}
+
+func (enc *encoder) item(item Item) {
+	switch v := item.(type) {
+	case nil, Nil:
+		enc.bytes([]byte{tagNil})
+	case True:
+		enc.bytes([]byte{tagTrue})
+	case False:
+		enc.bytes([]byte{tagFalse})
+
+	case Integer:
+		enc.bytes([]byte{tagInteger})
+		enc.int(v.Value)
+
+	case Float:
+		enc.bytes([]byte{tagFloat})
+		enc.bytes(binary.BigEndian.AppendUint64(nil, math.Float64bits(v.Value)))
+
+	case String:
+		enc.bytes([]byte{tagString})
+		enc.string(v.Value)
+
+	case Symbol:
+		enc.bytes([]byte{tagSymbol})
+		enc.string(v.Value)
+
+	case Keyword:
+		enc.bytes([]byte{tagKeyword})
+		enc.string(v.Value)
+
+	case List:
+		enc.bytes([]byte{tagList})
+		enc.items(v.Value)
+
+	case Vector:
+		enc.bytes([]byte{tagVector})
+		enc.items(v.Value)
+
+	case Hash:
+		enc.bytes([]byte{tagHash})
+		enc.uint(len(v.Value))
+		for _, kv := range v.Value {
+			enc.item(kv.Key)
+			enc.item(kv.Value)
+		}
+
+	case Local:
+		enc.bytes([]byte{tagLocal})
+		enc.string(v.Name)
+		enc.uint(v.Depth)
+		enc.uint(v.Index)
+
+	case Lambda:
+		enc.bytes([]byte{tagLambda})
+		enc.item(v.Params)
+		enc.items(v.Body)
+		enc.uint(v.Slots)
+		enc.uint(v.Required)
+		enc.bool(v.Variadic)
+
+	case *Chunk:
+		enc.bytes([]byte{tagChunk})
+		enc.string(v.Name)
+		enc.uint(len(v.Code))
+		enc.bytes(v.Code)
+		enc.items(v.Consts)
+		enc.uint(len(v.Calls))
+		for _, call := range v.Calls {
+			enc.string(call.Name)
+			enc.string(call.Position.File)
+			enc.uint(call.Position.Line)
+			enc.uint(call.Position.Column)
+		}
+		enc.uint(v.Slots)
+		enc.item(v.Params)
+		enc.uint(v.Required)
+		enc.bool(v.Variadic)
+
+	default:
+		if enc.err == nil {
+			enc.err = fmt.Errorf("cannot encode %v", item)
+		}
+	}
+}
+
+type decoder struct {
+	r *bytes.Reader
+}
+
+// count reads number of encoded things, each takes at least a byte so
+// corrupted input cannot make it allocate more than its own size
+func (dec *decoder) count() (int, error) {
+	v, err := binary.ReadUvarint(dec.r)
+	if err != nil {
+		return 0, err
+	}
+	if v > uint64(dec.r.Len()) {
+		return 0, fmt.Errorf("count %d exceeds input", v)
+	}
+	return int(v), nil
+}
+
+func (dec *decoder) uint() (int, error) {
+	v, err := binary.ReadUvarint(dec.r)
+	if err != nil {
+		return 0, err
+	}
+	if v > maxLength {
+		return 0, fmt.Errorf("value %d out of range", v)
+	}
+	return int(v), nil
+}
+
+func (dec *decoder) bool() (bool, error) {
+	v, err := dec.uint()
+	return v != 0, err
+}
+
+func (dec *decoder) string() (string, error) {
+	b, err := dec.bytes()
+	return string(b), err
+}
+
+func (dec *decoder) bytes() ([]byte, error) {
+	n, err := dec.count()
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(dec.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (dec *decoder) items() ([]Item, error) {
+	n, err := dec.count()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, n)
+	for i := range items {
+		if items[i], err = dec.item(); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func (dec *decoder) item() (Item, error) {
+	tag, err := dec.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case tagNil:
+		return Nil{}, nil
+	case tagTrue:
+		return True{}, nil
+	case tagFalse:
+		return False{}, nil
+
+	case tagInteger:
+		v, err := binary.ReadVarint(dec.r)
+		return Integer{Value: v}, err
+
+	case tagFloat:
+		var b [8]byte
+		if _, err := io.ReadFull(dec.r, b[:]); err != nil {
+			return nil, err
+		}
+		return Float{Value: math.Float64frombits(binary.BigEndian.Uint64(b[:]))}, nil
+
+	case tagString:
+		v, err := dec.string()
+		return String{Value: v}, err
+
+	case tagSymbol:
+		v, err := dec.string()
+		return NewSymbol(v), err
+
+	case tagKeyword:
+		v, err := dec.string()
+		return NewKeyword(v), err
+
+	case tagList:
+		items, err := dec.items()
+		return List{Value: items}, err
+
+	case tagVector:
+		items, err := dec.items()
+		return Vector{Value: items}, err
+
+	case tagHash:
+		n, err := dec.count()
+		if err != nil {
+			return nil, err
+		}
+
+		hash := Hash{}
+		for i := 0; i < n; i++ {
+			key, err := dec.item()
+			if err != nil {
+				return nil, err
+			}
+			value, err := dec.item()
+			if err != nil {
+				return nil, err
+			}
+			hash = hash.Add(KeyValue{Key: key, Value: value})
+		}
+		return hash, nil
+
+	case tagLocal:
+		l := Local{}
+		if l.Name, err = dec.string(); err != nil {
+			return nil, err
+		}
+		if l.Depth, err = dec.uint(); err != nil {
+			return nil, err
+		}
+		l.Index, err = dec.uint()
+		return l, err
+
+	case tagLambda:
+		return dec.lambda()
+
+	case tagChunk:
+		return dec.chunk()
+
+	default:
+		return nil, fmt.Errorf("unknown tag %d", tag)
+	}
+}
+
+func (dec *decoder) lambda() (Item, error) {
+	fn := Lambda{}
+
+	params, err := dec.item()
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	if fn.Params, ok = params.(Vector); !ok {
+		return nil, fmt.Errorf("fn expects a vector of params, got %v", params)
+	}
+
+	if fn.Body, err = dec.items(); err != nil {
+		return nil, err
+	}
+	if fn.Slots, err = dec.uint(); err != nil {
+		return nil, err
+	}
+	if int64(fn.Slots) > dec.r.Size() {
+		return nil, fmt.Errorf("%d slots exceed input", fn.Slots)
+	}
+	if fn.Required, err = dec.uint(); err != nil {
+		return nil, err
+	}
+	fn.Variadic, err = dec.bool()
+	return fn, err
+}
+
+func (dec *decoder) chunk() (Item, error) {
+	c := &Chunk{}
+
+	var err error
+	if c.Name, err = dec.string(); err != nil {
+		return nil, err
+	}
+	if c.Code, err = dec.bytes(); err != nil {
+		return nil, err
+	}
+	if c.Consts, err = dec.items(); err != nil {
+		return nil, err
+	}
+
+	calls, err := dec.count()
+	if err != nil {
+		return nil, err
+	}
+	c.Calls = make([]Frame, calls)
+	for i := range c.Calls {
+		call := &c.Calls[i]
+		if call.Name, err = dec.string(); err != nil {
+			return nil, err
+		}
+		if call.Position.File, err = dec.string(); err != nil {
+			return nil, err
+		}
+		if call.Position.Line, err = dec.uint(); err != nil {
+			return nil, err
+		}
+		if call.Position.Column, err = dec.uint(); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Slots, err = dec.uint(); err != nil {
+		return nil, err
+	}
+	// Every slot is bound by an encoded local
+	if int64(c.Slots) > dec.r.Size() {
+		return nil, fmt.Errorf("%d slots exceed input", c.Slots)
+	}
+	if c.Params, err = dec.item(); err != nil {
+		return nil, err
+	}
+	if _, ok := c.Params.(Nil); ok {
+		c.Params = nil
+	}
+	if c.Required, err = dec.uint(); err != nil {
+		return nil, err
+	}
+	if c.Variadic, err = dec.bool(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
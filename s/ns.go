@@ -0,0 +1,479 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultNamespace is the namespace interpreter starts in
+const DefaultNamespace = "user"
+
+// Namespace is a named environment holding definitions of one module.
+// Names of other namespaces are reachable through aliases (`str/trim`)
+// or referred directly (`trim`).
+type Namespace struct {
+	Name string
+
+	in      *Interpreter
+	env     *Env
+	mu      sync.RWMutex
+	aliases map[string]*Namespace
+	refers  map[string]*Namespace
+}
+
+// Env returns environment holding definitions of the namespace
+func (ns *Namespace) Env() *Env {
+	return ns.env
+}
+
+// resolve returns namespace for given alias or full name
+func (ns *Namespace) resolve(name string) (*Namespace, error) {
+	ns.mu.RLock()
+	target, ok := ns.aliases[name]
+	ns.mu.RUnlock()
+	if ok {
+		return target, nil
+	}
+
+	if target := ns.in.findNamespace(name); target != nil {
+		return target, nil
+	}
+	return nil, fmt.Errorf("no such namespace: %s", name)
+}
+
+// get looks up qualified name like `str/trim`
+func (ns *Namespace) get(name string) (Item, error) {
+	i := strings.Index(name, "/")
+	target, err := ns.resolve(name[:i])
+	if err != nil {
+		return nil, err
+	}
+
+	item, ok := target.env.getRef(name[i+1:])
+	if !ok {
+		return nil, fmt.Errorf("%s/%s is undefined", target.Name, name[i+1:])
+	}
+	return item, nil
+}
+
+// referred returns definition referred from another namespace
+func (ns *Namespace) referred(name string) (Item, bool) {
+	ns.mu.RLock()
+	from, ok := ns.refers[name]
+	ns.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	return from.env.getRef(name)
+}
+
+func (ns *Namespace) alias(name string, target *Namespace) {
+	ns.mu.Lock()
+	ns.aliases[name] = target
+	ns.mu.Unlock()
+}
+
+// refer makes given names of target namespace available unqualified,
+// all public names are referred when names is nil
+func (ns *Namespace) refer(target *Namespace, names []string) error {
+	if names == nil {
+		target.env.mu.RLock()
+		for name := range target.env.defs {
+			names = append(names, name)
+		}
+		target.env.mu.RUnlock()
+	}
+
+	// Names are resolved before locking, resolving them may lock ns
+	// itself or namespaces referring to it
+	for _, name := range names {
+		if _, ok := target.env.getRef(name); !ok {
+			return fmt.Errorf("%s/%s is undefined", target.Name, name)
+		}
+	}
+
+	ns.mu.Lock()
+	for _, name := range names {
+		ns.refers[name] = target
+	}
+	ns.mu.Unlock()
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// namespace returns namespace of given name, creating it when missing
+func (in *Interpreter) namespace(name string) *Namespace {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if ns, ok := in.namespaces[name]; ok {
+		return ns
+	}
+
+	ns := &Namespace{
+		Name:    name,
+		in:      in,
+		env:     in.env.NewChild(),
+		aliases: make(map[string]*Namespace),
+		refers:  make(map[string]*Namespace),
+	}
+	ns.env.ns = ns
+	in.namespaces[name] = ns
+
+	return ns
+}
+
+func (in *Interpreter) findNamespace(name string) *Namespace {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.namespaces[name]
+}
+
+// moduleLoad is a load of a module, concurrent requires of the module
+// wait for the one in flight
+type moduleLoad struct {
+	name string
+	done chan struct{}
+	ns   *Namespace
+	err  error
+	// waiting is load the loader of this module waits for, following
+	// it finds cycles between goroutines
+	waiting *moduleLoad
+}
+
+// requireKey is context key of the chain of loads a require runs in
+type requireKey struct{}
+
+// requireChain returns loads the evaluation of ctx is nested in
+func requireChain(ctx context.Context) []*moduleLoad {
+	chain, _ := ctx.Value(requireKey{}).([]*moduleLoad)
+	return chain
+}
+
+// cycle returns error naming loads from chain to target ones
+func cycle(chain []*moduleLoad, to ...*moduleLoad) error {
+	var names []string
+	for _, load := range append(chain[:len(chain):len(chain)], to...) {
+		names = append(names, load.name)
+	}
+	return fmt.Errorf("cyclic require: %s", strings.Join(names, " -> "))
+}
+
+// markLoaded records namespace defined without loading a module, e.g.
+// by an `ns` form, so requiring it does not look for a file
+func (in *Interpreter) markLoaded(name string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if _, ok := in.loads[name]; !ok {
+		load := &moduleLoad{name: name, done: make(chan struct{}), ns: in.namespaces[name]}
+		close(load.done)
+		in.loads[name] = load
+	}
+}
+
+// Require loads namespace from the search path unless it was loaded before
+func (in *Interpreter) Require(name string) (*Namespace, error) {
+	ctx, cancel := startBudget(context.Background(), in.limits)
+	defer cancel()
+
+	return in.require(ctx, name)
+}
+
+func (in *Interpreter) require(ctx context.Context, name string) (*Namespace, error) {
+	chain := requireChain(ctx)
+	for i, load := range chain {
+		if load.name == name {
+			return nil, cycle(chain[i:], load)
+		}
+	}
+
+	in.mu.Lock()
+	if load, ok := in.loads[name]; ok {
+		err := waitFor(chain, load)
+		in.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-load.done:
+			err = load.err
+		case <-ctx.Done():
+			err = context.Cause(ctx)
+		}
+
+		in.mu.Lock()
+		if len(chain) > 0 {
+			chain[len(chain)-1].waiting = nil
+		}
+		in.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return load.ns, nil
+	}
+
+	load := &moduleLoad{name: name, done: make(chan struct{})}
+	in.loads[name] = load
+	in.mu.Unlock()
+
+	load.ns, load.err = in.load(context.WithValue(ctx, requireKey{}, append(chain[:len(chain):len(chain)], load)), name)
+	in.mu.Lock()
+	if load.err != nil {
+		// Let the next require try again
+		delete(in.loads, name)
+	}
+	in.mu.Unlock()
+	close(load.done)
+
+	return load.ns, load.err
+}
+
+// waitFor records that the innermost load of chain waits for load, it
+// fails when load waits for one of chain in turn. It is called with
+// in.mu held.
+func waitFor(chain []*moduleLoad, load *moduleLoad) error {
+	if len(chain) == 0 {
+		return nil
+	}
+
+	path := []*moduleLoad{load}
+	for next := load.waiting; next != nil; next = next.waiting {
+		path = append(path, next)
+		if i := slices.Index(chain, next); i >= 0 {
+			return cycle(chain[i:], path...)
+		}
+	}
+
+	chain[len(chain)-1].waiting = load
+	return nil
+}
+
+// load evaluates module file of namespace name
+func (in *Interpreter) load(ctx context.Context, name string) (*Namespace, error) {
+	path, err := in.findModule(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := in.namespace(name)
+	if _, _, err := in.loadFile(ctx, path, ns); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// findModule looks up file of given namespace in the search path,
+// `util.strings` is expected in `util/strings.slang` or compiled in
+// `util/strings.slangc`
+func (in *Interpreter) findModule(name string) (string, error) {
+	file := filepath.Join(strings.Split(name, ".")...) + ".slang"
+	for _, dir := range in.path {
+		for _, ext := range []string{"", "c"} {
+			path := filepath.Join(dir, file+ext)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("cannot find %s in search path", file)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// namespaceOf returns namespace given environment belongs to
+func namespaceOf(env *Env) (*Namespace, error) {
+	for e := env; e != nil; e = e.parent {
+		if e.ns != nil {
+			return e.ns, nil
+		}
+	}
+	return nil, fmt.Errorf("namespaces are not available in this environment")
+}
+
+// isQualified returns true for symbols like `util/parse`
+func isQualified(name string) bool {
+	i := strings.Index(name, "/")
+	return i > 0 && i < len(name)-1
+}
+
+// evalNs implements `(ns name (:require spec...))`. It only prepares the
+// namespace, switching to it is done by the interpreter at top level.
+func evalNs(ctx context.Context, args []Item, env *Env) (*Namespace, error) {
+	current, err := namespaceOf(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("ns expects a name")
+	}
+	name, ok := args[0].(Symbol)
+	if !ok {
+		return nil, fmt.Errorf("ns expects a symbol name, got %s", printKey(args[0]))
+	}
+
+	ns := current.in.namespace(name.Value)
+	current.in.markLoaded(name.Value)
+
+	for _, clause := range args[1:] {
+		list, ok := clause.(List)
+		if !ok || len(list.Value) == 0 {
+			return nil, fmt.Errorf("unsupported ns clause %s", printKey(clause))
+		}
+
+		if kw, ok := list.Value[0].(Keyword); !ok || kw.Value != "require" {
+			return nil, fmt.Errorf("unsupported ns clause %s", printKey(list.Value[0]))
+		}
+		if err := requireSpecs(ctx, ns, list.Value[1:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return ns, nil
+}
+
+// evalRequire implements `(require util.strings [util.math :as m :refer [add]])`
+func evalRequire(ctx context.Context, args []Item, env *Env) (Item, error) {
+	ns, err := namespaceOf(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requireSpecs(ctx, ns, args); err != nil {
+		return nil, err
+	}
+	return Nil{}, nil
+}
+
+// evalRefer implements `(refer util.strings)` and `(refer util.strings :only [trim])`
+func evalRefer(ctx context.Context, args []Item, env *Env) (Item, error) {
+	ns, err := namespaceOf(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) != 1 && len(args) != 3 {
+		return nil, fmt.Errorf("refer expects a namespace and optional :only names")
+	}
+	name, ok := args[0].(Symbol)
+	if !ok {
+		return nil, fmt.Errorf("refer expects a symbol name, got %s", printKey(args[0]))
+	}
+
+	target, err := ns.resolve(name.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if len(args) == 3 {
+		if !args[1].Equal(NewKeyword("only")).IsTrue() {
+			return nil, fmt.Errorf("unsupported refer option %s", printKey(args[1]))
+		}
+		if names, err = symbolNames(args[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ns.refer(target, names); err != nil {
+		return nil, err
+	}
+	return Nil{}, nil
+}
+
+func requireSpecs(ctx context.Context, ns *Namespace, specs []Item) error {
+	for _, spec := range specs {
+		if err := requireSpec(ctx, ns, spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func requireSpec(ctx context.Context, ns *Namespace, spec Item) error {
+	var opts []Item
+	switch v := spec.(type) {
+	case Symbol:
+		_, err := ns.in.require(ctx, v.Value)
+		return err
+
+	case Vector:
+		if len(v.Value) == 0 || len(v.Value)%2 != 1 {
+			return fmt.Errorf("require expects [name & options], got %s", printKey(spec))
+		}
+		spec = v.Value[0]
+		opts = v.Value[1:]
+
+	default:
+		return fmt.Errorf("unsupported require spec %s", printKey(spec))
+	}
+
+	name, ok := spec.(Symbol)
+	if !ok {
+		return fmt.Errorf("require expects a symbol name, got %s", printKey(spec))
+	}
+
+	target, err := ns.in.require(ctx, name.Value)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(opts); i += 2 {
+		opt, ok := opts[i].(Keyword)
+		if !ok {
+			return fmt.Errorf("unsupported require option %s", printKey(opts[i]))
+		}
+
+		switch opt.Value {
+		case "as":
+			alias, ok := opts[i+1].(Symbol)
+			if !ok {
+				return fmt.Errorf(":as expects a symbol, got %s", printKey(opts[i+1]))
+			}
+			ns.alias(alias.Value, target)
+
+		case "refer":
+			var names []string
+			if !opts[i+1].Equal(NewKeyword("all")).IsTrue() {
+				if names, err = symbolNames(opts[i+1]); err != nil {
+					return err
+				}
+			}
+			if err := ns.refer(target, names); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("unsupported require option :%s", opt.Value)
+		}
+	}
+
+	return nil
+}
+
+func symbolNames(item Item) ([]string, error) {
+	vec, ok := item.(Vector)
+	if !ok {
+		return nil, fmt.Errorf("expected a vector of symbols, got %s", printKey(item))
+	}
+
+	names := []string{}
+	for _, i := range vec.Value {
+		sym, ok := i.(Symbol)
+		if !ok {
+			return nil, fmt.Errorf("expected a symbol, got %s", printKey(i))
+		}
+		names = append(names, sym.Value)
+	}
+	return names, nil
+}
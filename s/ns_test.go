@@ -0,0 +1,236 @@
+package s
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, code := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(code), 0644))
+	}
+	return dir
+}
+
+func TestInterpreter_Namespaces(t *testing.T) {
+	in := NewInterpreter(Options{})
+	assert.Equal(t, DefaultNamespace, in.Namespace().Name)
+
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{"(set parse 1)", "1"},
+		{"(ns util)", "nil"},
+		{"(set parse (fn [x] (+ x 100)))", "function"},
+		{"(parse 1)", "101"},
+		{"(ns user)", "nil"},
+		{"parse", "1"},
+		{"(util/parse 2)", "102"},
+		{"user/parse", "1"},
+		{"(refer util :only [parse])", "nil"},
+		{"parse", "1"},
+		{"(ns app (:require [util :as u]))", "nil"},
+		{"(u/parse 3)", "103"},
+		{"(refer util)", "nil"},
+		{"(parse 4)", "104"},
+		{"(+ 1 2)", "3"},
+	}
+
+	for _, c := range cases {
+		res, err := in.Rep(c.input)
+		assert.NoError(t, err)
+		assert.Equal(t, c.output, res, "%s should return %s", c.input, c.output)
+	}
+
+	_, err := in.Rep("(nope/parse)")
+	assert.EqualError(t, err, "no such namespace: nope")
+
+	_, err = in.Rep("(util/missing)")
+	assert.EqualError(t, err, "util/missing is undefined")
+
+	_, err = in.Rep("(do (ns other))")
+	assert.EqualError(t, err, "ns is only allowed at top level")
+}
+
+func TestInterpreter_ReferLocking(t *testing.T) {
+	in := NewInterpreter(Options{})
+	_, err := in.EvalString("(ns a) (set x 1) (ns b) (set y 2) (ns user)")
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// Missing names are looked up in refers of the namespace itself
+		_, err := in.Rep("(refer user :only [nope])")
+		assert.EqualError(t, err, "user/nope is undefined")
+
+		// Namespaces referring each other at once
+		a, b := in.findNamespace("a"), in.findNamespace("b")
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				assert.Error(t, a.refer(b, []string{"nope"}))
+			}()
+			go func() {
+				defer wg.Done()
+				assert.Error(t, b.refer(a, []string{"nope"}))
+			}()
+		}
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refer deadlocked")
+	}
+}
+
+func TestInterpreter_Require(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"util/strings.slang": `
+(ns util.strings)
+(println "loading util.strings")
+(set greet (fn [name] (list :hello name)))`,
+		"util/math.slang": `
+(ns util.math (:require [util.strings :as s]))
+(set twice (fn [x] (* 2 x)))
+(set greet-twice (fn [x] (s/greet (twice x))))`,
+	})
+
+	var out bytes.Buffer
+	in := NewInterpreter(Options{Path: []string{dir}, Stdout: &out})
+
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{"(require [util.math :as m :refer [twice]])", "nil"},
+		{"(twice 4)", "8"},
+		{"(m/greet-twice 2)", "(:hello 4)"},
+		{"(util.strings/greet 1)", "(:hello 1)"},
+		{"(require [util.strings :refer :all])", "nil"},
+		{"(greet 5)", "(:hello 5)"},
+		{"(require util.strings)", "nil"},
+	}
+
+	for _, c := range cases {
+		res, err := in.Rep(c.input)
+		assert.NoError(t, err)
+		assert.Equal(t, c.output, res, "%s should return %s", c.input, c.output)
+	}
+
+	// Modules are loaded only once
+	assert.Equal(t, "loading util.strings\n", out.String())
+
+	_, err := in.Rep("(require missing.module)")
+	assert.EqualError(t, err, "cannot find missing/module.slang in search path")
+}
+
+func TestInterpreter_RequireCycle(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.slang": "(ns a (:require b))",
+		"b.slang": "(ns b (:require c))",
+		"c.slang": "(ns c (:require a))",
+	})
+
+	in := NewInterpreter(Options{Path: []string{dir}})
+	_, err := in.Rep("(require a)")
+	assert.EqualError(t, err, "cyclic require: a -> b -> c -> a")
+
+	// Failed modules can be required again once fixed
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "c.slang"), []byte("(ns c)"), 0644))
+	_, err = in.Rep("(require a)")
+	assert.NoError(t, err)
+}
+
+func TestInterpreter_RequireConcurrent(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"slow.slang": "(ns slow) (println \"loading slow\") (sleep 50) (set done true)",
+		"x.slang":    "(ns x) (sleep 50) (require y)",
+		"y.slang":    "(ns y) (sleep 50) (require x)",
+	})
+
+	var out bytes.Buffer
+	in := NewInterpreter(Options{Path: []string{dir}, Stdout: &out})
+
+	// Concurrent requires wait for the load in flight
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ns, err := in.Require("slow")
+			if err == nil {
+				_, err = ns.Env().Get("done")
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "loading slow\n", out.String())
+
+	// Cycles between goroutines fail instead of waiting forever
+	for i, name := range []string{"x", "y"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = in.Require(name)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs[:2] {
+		assert.ErrorContains(t, err, "cyclic require: ")
+	}
+}
+
+func TestEnv_RequireWithoutInterpreter(t *testing.T) {
+	env := NewEnv()
+	env.Init()
+
+	_, err := Eval(context.Background(), List{Value: []Item{NewSymbol("require"), NewSymbol("a")}}, env)
+	assert.EqualError(t, err, "namespaces are not available in this environment")
+}
+
+func TestInterpreter_NamespaceErrors(t *testing.T) {
+	in := NewInterpreter(Options{})
+
+	cases := map[string]string{
+		"(ns 1)":                   "ns expects a symbol name, got 1",
+		"(ns app (:use util))":     "unsupported ns clause :use",
+		"(refer 1)":                "refer expects a symbol name, got 1",
+		"(refer user :except [x])": "unsupported refer option :except",
+		"(require [])":             "require expects [name & options], got []",
+		"(require 1)":              "unsupported require spec 1",
+		"(require [1])":            "require expects a symbol name, got 1",
+		`(require [user "as" u])`:  `unsupported require option "as"`,
+		"(require [user :as 1])":   ":as expects a symbol, got 1",
+		"(refer user :only 1)":     "expected a vector of symbols, got 1",
+		"(refer user :only [:a])":  "expected a symbol, got :a",
+	}
+
+	for code, msg := range cases {
+		_, err := in.EvalString(code)
+		assert.EqualError(t, err, msg, code)
+	}
+}
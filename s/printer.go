@@ -1,18 +1,32 @@
 package s
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
+// maxPrintedItems limits printed items of lazy sequences
+const maxPrintedItems = 100
+
 type Printer struct {
 	item Item
+	// ctx realizes lazy sequences, without it only their realized items
+	// are printed
+	ctx context.Context
 }
 
 func NewPrinter(item Item) *Printer {
 	return &Printer{item: item}
 }
 
+// NewContextPrinter returns printer realizing lazy sequences under ctx,
+// e.g. the evaluation which produced item
+func NewContextPrinter(ctx context.Context, item Item) *Printer {
+	return &Printer{item: item, ctx: ctx}
+}
+
 func (p *Printer) ToString() (string, error) {
 	return p.nodeToString(p.item)
 }
@@ -32,6 +46,47 @@ func (p *Printer) nodeToString(i Item) (string, error) {
 		}
 		output = "(" + strings.Join(body, " ") + ")"
 
+	case Cons, *LazySeq:
+		// Printing must end even for infinite sequences
+		var body []string
+		seq := v.(Seq)
+		ctx := p.ctx
+		if ctx == nil {
+			ctx = realizedOnly
+		}
+		for {
+			empty, err := seq.Empty(ctx)
+			if err != nil && p.ctx == nil {
+				body = append(body, "...")
+				break
+			}
+			if err != nil {
+				return output, err
+			}
+			if empty {
+				break
+			}
+			if len(body) == maxPrintedItems {
+				body = append(body, "...")
+				break
+			}
+
+			item, err := seq.First(ctx)
+			if err != nil {
+				return output, err
+			}
+			str, err := p.nodeToString(item)
+			if err != nil {
+				return output, err
+			}
+			body = append(body, str)
+
+			if seq, err = seq.Rest(ctx); err != nil {
+				return output, err
+			}
+		}
+		output = "(" + strings.Join(body, " ") + ")"
+
 	case Hash:
 		var body []string
 		for _, kv := range v.Value {
@@ -63,6 +118,13 @@ func (p *Printer) nodeToString(i Item) (string, error) {
 	case Integer:
 		output = fmt.Sprintf("%d", v.Value)
 
+	case Float:
+		output = strconv.FormatFloat(v.Value, 'g', -1, 64)
+		// Keep floats apart from integers when read back
+		if !strings.ContainsAny(output, ".eIN") {
+			output += ".0"
+		}
+
 	case Symbol:
 		output = fmt.Sprintf("%s", v.Value)
 
@@ -81,9 +143,42 @@ func (p *Printer) nodeToString(i Item) (string, error) {
 	case String:
 		output = fmt.Sprintf(`"%s"`, v.Value)
 
-	case Func:
+	case Func, Lambda:
 		output = "function"
 
+	case GoValue:
+		output = fmt.Sprintf("#<GoValue %T>", v.Value)
+
+	case *Atom:
+		str, err := p.nodeToString(v.Deref())
+		if err != nil {
+			return output, err
+		}
+		output = "#<Atom " + str + ">"
+
+	case *Future:
+		output = "#<Future>"
+
+	case Reduced:
+		str, err := p.nodeToString(v.Value)
+		if err != nil {
+			return output, err
+		}
+		output = "#<Reduced " + str + ">"
+
+	case *Channel:
+		output = "#<Channel>"
+
+	case Local:
+		output = v.Name
+
+	case ExInfo:
+		data, err := p.nodeToString(v.Data)
+		if err != nil {
+			return output, err
+		}
+		output = fmt.Sprintf(`#error {:message "%s" :data %s}`, v.Message, data)
+
 	default:
 		return "", fmt.Errorf("Unknown type '%s'", i)
 	}
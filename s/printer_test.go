@@ -13,14 +13,17 @@ var printTestcases = map[string]Item{
 	"false": False{},
 
 	// Numbers
-	"1": Integer{Value: 1},
-	"7": Integer{Value: 7},
+	"1":     Integer{Value: 1},
+	"7":     Integer{Value: 7},
+	"1.5":   Float{Value: 1.5},
+	"2.0":   Float{Value: 2},
+	"1e+21": Float{Value: 1e21},
 
 	// Symbols
-	"+":       Symbol{Value: "+"},
-	"abc":     Symbol{Value: "abc"},
-	"abc5":    Symbol{Value: "abc5"},
-	"abc-def": Symbol{Value: "abc-def"},
+	"+":       NewSymbol("+"),
+	"abc":     NewSymbol("abc"),
+	"abc5":    NewSymbol("abc5"),
+	"abc-def": NewSymbol("abc-def"),
 
 	// Strings
 	`"abc"`:               String{Value: "abc"},
@@ -30,7 +33,7 @@ var printTestcases = map[string]Item{
 
 	// Lists
 	"(+ 1 2)": List{Value: []Item{
-		Symbol{Value: "+"},
+		NewSymbol("+"),
 		Integer{Value: 1},
 		Integer{Value: 2},
 	}},
@@ -41,36 +44,39 @@ var printTestcases = map[string]Item{
 		}},
 	}},
 	"(+ 1 (+ 2 3))": List{Value: []Item{
-		Symbol{Value: "+"},
+		NewSymbol("+"),
 		Integer{Value: 1},
 		List{Value: []Item{
-			Symbol{Value: "+"},
+			NewSymbol("+"),
 			Integer{Value: 2},
 			Integer{Value: 3},
 		}},
 	}},
 	"(* 1 2)": List{Value: []Item{
-		Symbol{Value: "*"},
+		NewSymbol("*"),
 		Integer{Value: 1},
 		Integer{Value: 2},
 	}},
 	"(** 1 2)": List{Value: []Item{
-		Symbol{Value: "**"},
+		NewSymbol("**"),
 		Integer{Value: 1},
 		Integer{Value: 2},
 	}},
 
 	// Keywords
-	":kw": Keyword{Value: "kw"},
+	":kw": NewKeyword("kw"),
 
 	// Map
 	`{"a" 1}`: Hash{Value: []KeyValue{
 		KeyValue{Key: String{Value: "a"}, Value: Integer{Value: 1}},
 	}},
 
+	// Errors
+	`#error {:message "boom" :data {}}`: ExInfo{Message: "boom", Data: Hash{}},
+
 	// Vector
 	"[+ 1 2]": Vector{Value: []Item{
-		Symbol{Value: "+"},
+		NewSymbol("+"),
 		Integer{Value: 1},
 		Integer{Value: 2},
 	}},
@@ -9,16 +9,23 @@ import (
 )
 
 type Reader struct {
-	position int
-	tokens   []string
+	position  int
+	tokens    []string
+	positions []Position
+	file      string
 }
 
 func NewReader() *Reader {
 	return &Reader{position: -1}
 }
 
+// NewFileReader returns reader which records given file name in positions
+func NewFileReader(file string) *Reader {
+	return &Reader{position: -1, file: file}
+}
+
 func (r *Reader) Parse(code string) (Item, error) {
-	r.tokens = r.Tokenize(code)
+	r.tokens, r.positions = r.tokenize(code)
 	if len(r.tokens) == 0 {
 		return nil, fmt.Errorf("unexpected EOF while reading")
 	}
@@ -26,12 +33,31 @@ func (r *Reader) Parse(code string) (Item, error) {
 	return r.ReadFromTokens()
 }
 
+// ParseAll reads every form from given code
+func (r *Reader) ParseAll(code string) ([]Item, error) {
+	r.tokens, r.positions = r.tokenize(code)
+
+	items := []Item{}
+	for r.position+1 < len(r.tokens) {
+		item, err := r.ReadFromTokens()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
 func (r *Reader) ReadFromTokens() (Item, error) {
-	var i Item
+	if r.position+1 >= len(r.tokens) {
+		return nil, fmt.Errorf("unexpected EOF while reading")
+	}
 	token := r.peek()
 
 	switch token {
 	case "(":
+		pos := r.positions[r.position]
 		i := List{Value: []Item{}}
 		for {
 			cn, err := r.ReadFromTokens()
@@ -45,14 +71,19 @@ func (r *Reader) ReadFromTokens() (Item, error) {
 				break
 			}
 		}
-		return i, nil
+		return withPosition(i, pos), nil
 
 	case ")":
-		return nil, fmt.Errorf("unexpected ) at %d", r.position)
+		return nil, fmt.Errorf("unexpected ) at %s", r.positions[r.position])
 
 	case "{":
 		i := Hash{}
 		for {
+			if r.next() == "}" {
+				r.peek() // Move to next one
+				break
+			}
+
 			key, err := r.ReadFromTokens()
 			if err != nil {
 				return nil, err
@@ -63,16 +94,11 @@ func (r *Reader) ReadFromTokens() (Item, error) {
 			}
 			kv := KeyValue{Key: key, Value: value}
 			i = i.Add(kv)
-
-			if r.next() == "}" {
-				r.peek() // Move to next one
-				break
-			}
 		}
 		return i, nil
 
 	case "}":
-		return nil, fmt.Errorf("unexpected } at %d", r.position)
+		return nil, fmt.Errorf("unexpected } at %s", r.positions[r.position])
 
 	case "[":
 		i := Vector{}
@@ -91,28 +117,59 @@ func (r *Reader) ReadFromTokens() (Item, error) {
 		return i, nil
 
 	case "]":
-		return nil, fmt.Errorf("unexpected ] at %d", r.position)
+		return nil, fmt.Errorf("unexpected ] at %s", r.positions[r.position])
+
+	case "@":
+		// @x reads as (deref x)
+		pos := r.positions[r.position]
+		form, err := r.ReadFromTokens()
+		if err != nil {
+			return nil, err
+		}
+		i := List{Value: []Item{NewSymbol("deref"), form}}
+		return withPosition(i, pos), nil
 
 	default:
 		return r.readAtom(token)
 	}
-
-	return i, nil
 }
 
 func (r *Reader) Tokenize(code string) []string {
+	tokens, _ := r.tokenize(code)
+	return tokens
+}
+
+// tokenize splits code into tokens and returns position of every token
+func (r *Reader) tokenize(code string) ([]string, []Position) {
 	results := make([]string, 0, 1)
+	positions := make([]Position, 0, 1)
 	// Work around lack of quoting in backtick
 	re := regexp.MustCompile(`[\s,]*(~@|[\[\]{}()'` + "`" +
 		`~^@]|"(?:\\.|[^\\"])*"|;.*|[^\s\[\]{}('"` + "`" +
 		`,;)]*)`)
-	for _, group := range re.FindAllStringSubmatch(code, -1) {
-		if (group[1] == "") || (group[1][0] == ';') {
+
+	line, column, offset := 1, 1, 0
+	for _, group := range re.FindAllStringSubmatchIndex(code, -1) {
+		token := code[group[2]:group[3]]
+		if (token == "") || (token[0] == ';') {
 			continue
 		}
-		results = append(results, group[1])
+
+		// Advance line and column up to the token start
+		for _, c := range code[offset:group[2]] {
+			if c == '\n' {
+				line++
+				column = 1
+			} else {
+				column++
+			}
+		}
+		offset = group[2]
+
+		results = append(results, token)
+		positions = append(positions, Position{File: r.file, Line: line, Column: column})
 	}
-	return results
+	return results, positions
 }
 
 func (r *Reader) peek() string {
@@ -122,11 +179,21 @@ func (r *Reader) peek() string {
 }
 
 func (r *Reader) next() string {
+	if r.position+1 >= len(r.tokens) {
+		return ""
+	}
 	return r.tokens[r.position+1]
 }
 
 func (r *Reader) readAtom(token string) (Item, error) {
 	switch {
+	case unicode.IsNumber(rune(token[0])) && strings.ContainsAny(token, ".eE"):
+		val, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, err
+		}
+		return Float{Value: val}, nil
+
 	case unicode.IsNumber(rune(token[0])):
 		i := Integer{}
 		val, err := strconv.Atoi(token)
@@ -143,12 +210,12 @@ func (r *Reader) readAtom(token string) (Item, error) {
 		val = token[1 : len(token)-1]
 		val = strings.Replace(val, `\"`, `"`, -1)
 		val = strings.Replace(val, `\n`, "\n", -1)
-		i.Value = val
+		// Copy, so parsed data doesn't keep whole source alive
+		i.Value = strings.Clone(val)
 		return i, nil
 
 	case string(token[0]) == ":":
-		i := Keyword{Value: token[1:]}
-		return i, nil
+		return NewKeyword(token[1:]), nil
 
 	case token == "nil":
 		return Nil{}, nil
@@ -157,6 +224,6 @@ func (r *Reader) readAtom(token string) (Item, error) {
 	case token == "false":
 		return False{}, nil
 	default:
-		return Symbol{Value: token}, nil
+		return NewSymbol(token), nil
 	}
 }
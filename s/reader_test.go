@@ -1,7 +1,11 @@
 package s
 
 import (
+	"fmt"
+	"runtime"
+	"strings"
 	"testing"
+	"unsafe"
 
 	// "github.com/k0kubun/pp"
 	"github.com/stretchr/testify/assert"
@@ -17,13 +21,15 @@ var testcases = map[string]Item{
 	"1":     Integer{Value: 1},
 	"7":     Integer{Value: 7},
 	"  7  ": Integer{Value: 7},
+	"1.5":   Float{Value: 1.5},
+	"2e3":   Float{Value: 2000},
 
 	// Symbols
-	"+":         Symbol{Value: "+"},
-	"abc":       Symbol{Value: "abc"},
-	"   abc   ": Symbol{Value: "abc"},
-	"abc5":      Symbol{Value: "abc5"},
-	"abc-def":   Symbol{Value: "abc-def"},
+	"+":         NewSymbol("+"),
+	"abc":       NewSymbol("abc"),
+	"   abc   ": NewSymbol("abc"),
+	"abc5":      NewSymbol("abc5"),
+	"abc-def":   NewSymbol("abc-def"),
 
 	// Strings
 	`"abc"`:               String{Value: "abc"},
@@ -34,7 +40,7 @@ var testcases = map[string]Item{
 
 	// Lists
 	"(+ 1 2)": List{Value: []Item{
-		Symbol{Value: "+"},
+		NewSymbol("+"),
 		Integer{Value: 1},
 		Integer{Value: 2},
 	}},
@@ -45,30 +51,30 @@ var testcases = map[string]Item{
 		}},
 	}},
 	"(+ 1 (+ 2 3))": List{Value: []Item{
-		Symbol{Value: "+"},
+		NewSymbol("+"),
 		Integer{Value: 1},
 		List{Value: []Item{
-			Symbol{Value: "+"},
+			NewSymbol("+"),
 			Integer{Value: 2},
 			Integer{Value: 3},
 		}},
 	}},
 	"  ( +   1   (+   2 3   )   )  ": List{Value: []Item{
-		Symbol{Value: "+"},
+		NewSymbol("+"),
 		Integer{Value: 1},
 		List{Value: []Item{
-			Symbol{Value: "+"},
+			NewSymbol("+"),
 			Integer{Value: 2},
 			Integer{Value: 3},
 		}},
 	}},
 	"(* 1 2)": List{Value: []Item{
-		Symbol{Value: "*"},
+		NewSymbol("*"),
 		Integer{Value: 1},
 		Integer{Value: 2},
 	}},
 	"(** 1 2)": List{Value: []Item{
-		Symbol{Value: "**"},
+		NewSymbol("**"),
 		Integer{Value: 1},
 		Integer{Value: 2},
 	}},
@@ -81,14 +87,15 @@ var testcases = map[string]Item{
 	}},
 
 	// Keywords
-	":kw": Keyword{Value: "kw"},
+	":kw": NewKeyword("kw"),
 	"(:kw1 :kw2 :kw3)": List{Value: []Item{
-		Keyword{Value: "kw1"},
-		Keyword{Value: "kw2"},
-		Keyword{Value: "kw3"},
+		NewKeyword("kw1"),
+		NewKeyword("kw2"),
+		NewKeyword("kw3"),
 	}},
 
 	// Hash
+	"{}": Hash{},
 	`{"abc" 1}`: Hash{Value: []KeyValue{
 		KeyValue{Key: String{Value: "abc"}, Value: Integer{Value: 1}},
 	}},
@@ -100,7 +107,7 @@ var testcases = map[string]Item{
 
 	// Vector
 	"[+ 1 2]": Vector{Value: []Item{
-		Symbol{Value: "+"},
+		NewSymbol("+"),
 		Integer{Value: 1},
 		Integer{Value: 2},
 	}},
@@ -119,7 +126,197 @@ func TestReader_Parse(t *testing.T) {
 
 		assert.NoError(t, err)
 		if assert.NotNil(t, n) {
-			assert.Equal(t, node, n)
+			assert.Equal(t, node, stripPositions(n))
+		}
+	}
+}
+
+// stripPositions returns item with source positions of its lists
+// cleared, they are checked by TestPositionOf
+func stripPositions(item Item) Item {
+	switch v := item.(type) {
+	case List:
+		values := make([]Item, len(v.Value))
+		for i, elem := range v.Value {
+			values[i] = stripPositions(elem)
+		}
+		return List{Value: values}
+	case Vector:
+		values := make([]Item, len(v.Value))
+		for i, elem := range v.Value {
+			values[i] = stripPositions(elem)
+		}
+		return Vector{Value: values}
+	case Hash:
+		if v.Value == nil {
+			return v
+		}
+		kvs := make([]KeyValue, len(v.Value))
+		for i, kv := range v.Value {
+			kvs[i] = KeyValue{Key: stripPositions(kv.Key), Value: stripPositions(kv.Value)}
+		}
+		return Hash{Value: kvs}
+	}
+	return item
+}
+
+func TestReader_ParseAll(t *testing.T) {
+	r := NewReader()
+	items, err := r.ParseAll("1 (+ 1 2)\n:kw ; comment")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Item{
+		Integer{Value: 1},
+		withPosition(List{Value: []Item{
+			NewSymbol("+"),
+			Integer{Value: 1},
+			Integer{Value: 2},
+		}}, Position{Line: 1, Column: 3}),
+		NewKeyword("kw"),
+	}, items)
+}
+
+func TestReader_ParseErrors(t *testing.T) {
+	cases := map[string]string{
+		"":         "unexpected EOF while reading",
+		"(+ 1":     "unexpected EOF while reading",
+		"[1 2":     "unexpected EOF while reading",
+		"{:a":      "unexpected EOF while reading",
+		"(+ 1\n)]": "",
+		")":        "unexpected ) at 1:1",
+		"(1 }":     "unexpected } at 1:4",
+	}
+
+	for code, msg := range cases {
+		_, err := NewReader().Parse(code)
+		if msg == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, msg, code)
+		}
+	}
+
+	_, err := NewFileReader("lib.slang").Parse("\n  ]")
+	assert.EqualError(t, err, "unexpected ] at lib.slang:2:3")
+}
+
+func TestReader_Interning(t *testing.T) {
+	items, err := NewReader().ParseAll("foo :bar (foo :bar)")
+	assert.NoError(t, err)
+
+	inner := items[2].(List).Value
+	assert.Same(t, unsafe.StringData(items[0].(Symbol).Value), unsafe.StringData(inner[0].(Symbol).Value))
+	assert.Same(t, unsafe.StringData(items[1].(Keyword).Value), unsafe.StringData(inner[1].(Keyword).Value))
+
+	// Names are compared by handle, which keeps them interned across
+	// garbage collections
+	kw := NewKeyword("interned-" + strings.Repeat("x", 3))
+	runtime.GC()
+	assert.True(t, kw.Equal(NewKeyword("interned-xxx")).IsTrue())
+	assert.True(t, NewSymbol("foo").Equal(NewSymbol("fo")).IsFalse())
+	assert.True(t, NewSymbol("foo").Equal(NewKeyword("foo")).IsFalse())
+
+	hash := Hash{}.Add(KeyValue{Key: NewKeyword("a"), Value: Integer{Value: 1}})
+	value, ok := hash.Get(NewKeyword("a"))
+	assert.True(t, ok)
+	assert.Equal(t, Integer{Value: 1}, value)
+	_, ok = hash.Get(NewSymbol("a"))
+	assert.False(t, ok)
+
+	// Literals built by embedders have no handle and compare by value
+	cfg := Hash{}.
+		Add(KeyValue{Key: Keyword{Value: "host"}, Value: String{Value: "localhost"}}).
+		Add(KeyValue{Key: Keyword{Value: "port"}, Value: Integer{Value: 80}})
+	for _, key := range []Item{Keyword{Value: "port"}, NewKeyword("port")} {
+		value, ok = cfg.Get(key)
+		assert.True(t, ok)
+		assert.Equal(t, Integer{Value: 80}, value)
+	}
+	assert.True(t, Symbol{Value: "a"}.Equal(Symbol{Value: "b"}).IsFalse())
+	assert.True(t, Symbol{Value: "a"}.Equal(NewSymbol("a")).IsTrue())
+
+	in := NewInterpreter(Options{})
+	in.Env().Define("cfg", cfg)
+	res, err := in.Rep("(list (get cfg :port) (count (distinct (list :port (first (keys cfg)) (nth (keys cfg) 1)))))")
+	assert.NoError(t, err)
+	assert.Equal(t, "(80 2)", res)
+}
+
+func BenchmarkReader_Parse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for code := range testcases {
+			if _, err := NewReader().Parse(code); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// dataFile returns a vector of records which share their keys
+func dataFile(records int) string {
+	var code strings.Builder
+	code.WriteString("[")
+	for i := 0; i < records; i++ {
+		fmt.Fprintf(&code, "{:id %d :name \"user%d\" :status :active :tags [:admin :staff]}\n", i, i)
+	}
+	code.WriteString("]")
+	return code.String()
+}
+
+func BenchmarkReader_Data(b *testing.B) {
+	code := dataFile(1000)
+
+	// Memory kept by parsed data once its source is gone
+	const copies = 20
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	parsed := make([]Item, copies)
+	for i := range parsed {
+		item, err := NewReader().Parse(strings.Clone(code))
+		if err != nil {
+			b.Fatal(err)
+		}
+		parsed[i] = item
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(parsed)
+	retained := float64(after.HeapAlloc-before.HeapAlloc) / copies
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewReader().Parse(code); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(retained, "retained-B")
+}
+
+func BenchmarkReader_KeywordLookup(b *testing.B) {
+	var keys []string
+	for i := 0; i < 32; i++ {
+		keys = append(keys, fmt.Sprintf(":some-long-attribute-name-%02d %d", i, i))
+	}
+
+	data, err := NewReader().Parse("{" + strings.Join(keys, " ") + "}")
+	if err != nil {
+		b.Fatal(err)
+	}
+	key, err := NewReader().Parse(":some-long-attribute-name-31")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	hash := data.(Hash)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := hash.Get(key); !ok {
+			b.Fatal("key not found")
 		}
 	}
 }
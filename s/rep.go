@@ -1,8 +1,12 @@
 package s
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
-var environment = NewEnv()
+// defaultInterpreter backs package level Rep
+var defaultInterpreter = NewInterpreter(Options{})
 
 func read(input string) (Item, error) {
 	r := NewReader()
@@ -10,94 +14,247 @@ func read(input string) (Item, error) {
 	return node, err
 }
 
-func evalFn(rest []Item, env *Env) (Item, error) {
-	fn := Func{Value: func(args []Item) (Item, error) {
-		fnEnv := env.NewChild()
-		defs := rest[0].(Vector).Value
-		for i, arg := range args {
-			fnEnv.Define(defs[i].(Symbol).Value, arg)
+// evalLambda creates function closed over given environment
+func evalLambda(fn Lambda, env *Env) Func {
+	return Func{Value: func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) < fn.Required || (!fn.Variadic && len(args) > fn.Required) {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to fn", len(args))
 		}
 
-		return Eval(rest[1], fnEnv)
-	}}
+		b := budgetOf(ctx)
+		if err := b.enter(); err != nil {
+			return nil, err
+		}
+		defer b.leave()
+
+		frame := env.newFrame(fn.Slots)
+		if err := bind(ctx, fn.Params, List{Value: args}, frame); err != nil {
+			return nil, err
+		}
 
-	return fn, nil
+		return evalDo(ctx, fn.Body, frame)
+	}}
 }
 
-func evalSet(args []Item, env *Env) (Item, error) {
+// evalSet defines name in the global environment, also when called
+// from inside of a function
+func evalSet(ctx context.Context, args []Item, env *Env) (Item, error) {
 	name := args[0].(Symbol)
-	value := args[1]
+	value, err := eval(ctx, args[1], env)
+	if err != nil {
+		return nil, err
+	}
 
-	if value.IsList() {
+	env.globals().Define(name.Value, value)
+
+	return value, nil
+}
+
+// evalLet binds analyzed `(let [pattern value ...] body...)` into slots
+// of the current frame
+func evalLet(ctx context.Context, args []Item, env *Env) (Item, error) {
+	bindings := args[0].(Vector)
+
+	// Bind left to right, so every value can refer to previous names
+	for i := 0; i < len(bindings.Value); i += 2 {
+		value, err := eval(ctx, bindings.Value[i+1], env)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := bind(ctx, bindings.Value[i], value, env); err != nil {
+			return nil, err
+		}
+	}
+
+	// Eval code inside of let
+	return evalDo(ctx, args[1:], env)
+}
+
+// evalDo evaluates all expressions in order and returns the last result
+func evalDo(ctx context.Context, args []Item, env *Env) (Item, error) {
+	var result Item = Nil{}
+	for _, exp := range args {
 		var err error
-		value, err = Eval(value, env)
+		result, err = eval(ctx, exp, env)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	env.Define(name.Value, value)
+	return result, nil
+}
 
-	return value, nil
+func evalIf(ctx context.Context, args []Item, env *Env) (Item, error) {
+	cond, err := eval(ctx, args[0], env)
+	if err != nil {
+		return nil, err
+	}
+
+	ifTrue := args[1]
+	var ifFalse Item
+	if len(args) == 3 {
+		ifFalse = args[2]
+	} else {
+		ifFalse = Nil{}
+	}
+
+	if !Truthy(cond) {
+		return eval(ctx, ifFalse, env)
+	}
+	return eval(ctx, ifTrue, env)
 }
 
-func evalLet(args []Item, env *Env) (Item, error) {
-	childEnv := env.NewChild()
+func evalWhen(ctx context.Context, args []Item, env *Env, expected bool) (Item, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing condition")
+	}
+
+	cond, err := eval(ctx, args[0], env)
+	if err != nil {
+		return nil, err
+	}
 
-	// Set env variables
-	vars := args[0].(Hash)
+	if Truthy(cond) != expected {
+		return Nil{}, nil
+	}
+	return evalDo(ctx, args[1:], env)
+}
 
-	for _, kv := range vars.Value {
-		var value Item
+func evalAnd(ctx context.Context, args []Item, env *Env) (Item, error) {
+	var result Item = True{}
+	for _, exp := range args {
 		var err error
+		result, err = eval(ctx, exp, env)
+		if err != nil {
+			return nil, err
+		}
 
-		switch v := kv.Value.(type) {
-		case List:
-			value, err = Eval(v, childEnv)
-			if err != nil {
-				return nil, err
-			}
-		case Symbol:
-			value, err = Eval(v, childEnv)
-			if err != nil {
-				return nil, err
-			}
-		default:
-			value = kv.Value
+		if !Truthy(result) {
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+func evalOr(ctx context.Context, args []Item, env *Env) (Item, error) {
+	var result Item = Nil{}
+	for _, exp := range args {
+		var err error
+		result, err = eval(ctx, exp, env)
+		if err != nil {
+			return nil, err
 		}
 
-		name := kv.Key.(Symbol).Value
-		childEnv.Define(name, value)
+		if Truthy(result) {
+			return result, nil
+		}
 	}
 
-	// Eval code inside of let
-	exps := args[1]
-	result, err := Eval(exps, childEnv)
+	return result, nil
+}
+
+func evalCond(ctx context.Context, args []Item, env *Env) (Item, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("cond expects an even number of forms")
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		test, err := eval(ctx, args[i], env)
+		if err != nil {
+			return nil, err
+		}
+
+		if Truthy(test) {
+			return eval(ctx, args[i+1], env)
+		}
+	}
+
+	return Nil{}, nil
+}
+
+// evalCase dispatches on constants which are not evaluated, a list of
+// constants matches any of them and an odd trailing form is the default
+func evalCase(ctx context.Context, args []Item, env *Env) (Item, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing case expression")
+	}
+
+	value, err := eval(ctx, args[0], env)
 	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	clauses := args[1:]
+	for i := 0; i+1 < len(clauses); i += 2 {
+		var consts []Item
+		if list, ok := clauses[i].(List); ok {
+			consts = list.Value
+		} else {
+			consts = []Item{clauses[i]}
+		}
+
+		for _, c := range consts {
+			if c.Equal(value).IsTrue() {
+				return eval(ctx, clauses[i+1], env)
+			}
+		}
+	}
+
+	if len(clauses)%2 == 1 {
+		return eval(ctx, clauses[len(clauses)-1], env)
+	}
+
+	str, err := printContext(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no matching clause: %s", str)
 }
 
-func evalIf(args []Item, env *Env) (Item, error) {
-	cond := args[0]
-	ifTrue := args[1]
-	var ifFalse Item
-	if len(args) == 3 {
-		ifFalse = args[2]
-	} else {
-		ifFalse = Nil{}
+// Eval executes code. Form is analyzed first, so local variables are
+// addressed by frame and slot instead of looking them up by name. The
+// result is then run by the backend of the interpreter env belongs to.
+// Evaluation stops once ctx is done or it goes over limits of the
+// interpreter.
+func Eval(ctx context.Context, root Item, env *Env) (Item, error) {
+	node, slots, err := analyze(root)
+	if err != nil {
+		return nil, err
 	}
 
-	if cond.IsFalse() || cond.IsNil() {
-		return Eval(ifFalse, env)
+	ctx, cancel := startBudget(ctx, limitsOf(env))
+	defer cancel()
+
+	switch backendOf(env) {
+	case BackendClosure:
+		code, err := compile(node)
+		if err != nil {
+			return nil, err
+		}
+		if slots > 0 {
+			env = env.newFrame(slots)
+		}
+		return code(ctx, env)
+
+	case BackendVM:
+		chunk, err := assembleMain(node, slots)
+		if err != nil {
+			return nil, err
+		}
+		return chunk.Run(ctx, env)
+
+	default:
+		if slots > 0 {
+			env = env.newFrame(slots)
+		}
+		return eval(ctx, node, env)
 	}
-	return Eval(ifTrue, env)
 }
 
-// Eval executes code
-func Eval(root Item, env *Env) (Item, error) {
+// eval executes analyzed code
+func eval(ctx context.Context, root Item, env *Env) (Item, error) {
 	switch v := root.(type) {
 	case List:
 		// Return empty list
@@ -114,43 +271,79 @@ func Eval(root Item, env *Env) (Item, error) {
 		}
 
 		switch name {
-		case "fn":
-			return evalFn(rest, env)
-
 		case "set":
-			return evalSet(rest, env)
+			return evalSet(ctx, rest, env)
 
 		case "let":
-			return evalLet(rest, env)
+			return evalLet(ctx, rest, env)
+
+		case "do":
+			return evalDo(ctx, rest, env)
 
 		case "if":
-			return evalIf(rest, env)
+			return evalIf(ctx, rest, env)
+
+		case "when":
+			return evalWhen(ctx, rest, env, true)
+
+		case "unless":
+			return evalWhen(ctx, rest, env, false)
+
+		case "and":
+			return evalAnd(ctx, rest, env)
+
+		case "or":
+			return evalOr(ctx, rest, env)
+
+		case "cond":
+			return evalCond(ctx, rest, env)
+
+		case "case":
+			return evalCase(ctx, rest, env)
+
+		case "try":
+			return evalTry(ctx, rest, env)
+
+		case "ns":
+			return nil, fmt.Errorf("ns is only allowed at top level")
+
+		case "require":
+			return evalRequire(ctx, rest, env)
+
+		case "refer":
+			return evalRefer(ctx, rest, env)
 
 		default:
-			fn, err := Eval(head, env)
+			fn, err := eval(ctx, head, env)
 			if err != nil {
 				return nil, err
 			}
 
-			if !fn.IsFunc() {
+			f, ok := callable(fn)
+			if !ok {
 				return nil, fmt.Errorf("Unexpected type of %v", fn)
 			}
 
 			// Transform everything to Item value
+			args := make([]Item, len(rest))
 			for i, item := range rest {
-				output, err := Eval(item, env)
+				output, err := eval(ctx, item, env)
 				if err != nil {
 					return nil, err
 				}
 
-				rest[i] = output
+				args[i] = output
 			}
 
-			val, err := fn.(Func).Value(rest)
-			if err != nil {
+			if err := step(ctx); err != nil {
 				return nil, err
 			}
 
+			val, err := f.Value(ctx, args)
+			if err != nil {
+				return nil, withFrame(err, callName(head), v)
+			}
+
 			return val, nil
 		}
 
@@ -158,6 +351,20 @@ func Eval(root Item, env *Env) (Item, error) {
 		val, err := env.Get(v.Value)
 		return val, err
 
+	case Local:
+		frame := env
+		for i := 0; i < v.Depth; i++ {
+			frame = frame.parent
+		}
+
+		if item := frame.slots[v.Index]; item != nil {
+			return item, nil
+		}
+		return nil, fmt.Errorf("%s is undefined", v.Name)
+
+	case Lambda:
+		return evalLambda(v, env), nil
+
 	default:
 		return v, nil
 	}
@@ -172,23 +379,13 @@ func print(exp Item) (string, error) {
 	return output, nil
 }
 
-// Rep is an read-eval-print implementation
-func Rep(input string) (string, error) {
-	environment.Init()
-	ast, err := read(input)
-	if err != nil {
-		return "", err
-	}
-
-	exp, err := Eval(ast, environment)
-	if err != nil {
-		return "", err
-	}
-
-	output, err := print(exp)
-	if err != nil {
-		return "", err
-	}
+// printContext prints exp realizing its lazy sequences under ctx
+func printContext(ctx context.Context, exp Item) (string, error) {
+	return NewContextPrinter(ctx, exp).ToString()
+}
 
-	return output, nil
+// Rep is an read-eval-print implementation, all calls share the same
+// default interpreter. Use NewInterpreter to get an isolated one.
+func Rep(input string) (string, error) {
+	return defaultInterpreter.Rep(input)
 }
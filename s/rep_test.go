@@ -1,6 +1,7 @@
 package s
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,11 +24,11 @@ func TestEval(t *testing.T) {
 			expected: String{Value: "1"},
 		},
 		{ // :key => :key
-			root:     Keyword{Value: "key"},
-			expected: Keyword{Value: "key"},
+			root:     NewKeyword("key"),
+			expected: NewKeyword("key"),
 		},
 		{ // + => Func
-			root: Symbol{Value: "+"},
+			root: NewSymbol("+"),
 			expected: func() Item {
 				i, _ := env.Get("+")
 				return i
@@ -39,7 +40,7 @@ func TestEval(t *testing.T) {
 		},
 		{ // (+ 1 1) => 2
 			root: List{Value: []Item{
-				Symbol{Value: "+"},
+				NewSymbol("+"),
 				Integer{Value: 1},
 				Integer{Value: 1},
 			}},
@@ -48,15 +49,15 @@ func TestEval(t *testing.T) {
 		{ // ((fn [a b] (+ a b)) 1 1) => 2
 			root: List{Value: []Item{
 				List{Value: []Item{
-					Symbol{Value: "fn"},
+					NewSymbol("fn"),
 					Vector{Value: []Item{
-						Symbol{Value: "a"},
-						Symbol{Value: "b"},
+						NewSymbol("a"),
+						NewSymbol("b"),
 					}},
 					List{Value: []Item{
-						Symbol{Value: "+"},
-						Symbol{Value: "a"},
-						Symbol{Value: "b"},
+						NewSymbol("+"),
+						NewSymbol("a"),
+						NewSymbol("b"),
 					}},
 				}},
 				Integer{Value: 1},
@@ -67,7 +68,7 @@ func TestEval(t *testing.T) {
 	}
 
 	for _, test := range evalTests {
-		actual, err := Eval(test.root, env)
+		actual, err := Eval(context.Background(), test.root, env)
 
 		assert.NoError(t, err)
 		assert.Equal(t, test.expected, actual)
@@ -91,58 +92,77 @@ func TestRep(t *testing.T) {
 	}
 }
 
-func TestRep_Set(t *testing.T) {
-	res1, err1 := Rep(`(set x 2)`)
-	assert.NoError(t, err1)
-	assert.Equal(t, "2", res1)
-
-	res2, err2 := Rep("x")
-	assert.NoError(t, err2)
-	assert.Equal(t, "2", res2)
+// repCase is a single step of a scenario, later steps can depend on
+// definitions made by earlier ones
+type repCase struct {
+	input  string
+	output string
+	err    string
+}
 
-	res3, err3 := Rep("(+ 2 x)")
-	assert.NoError(t, err3)
-	assert.Equal(t, "4", res3)
+// runRepCases runs cases in order through rep and checks every result
+func runRepCases(t *testing.T, rep func(string) (string, error), cases []repCase) {
+	for _, c := range cases {
+		res, err := rep(c.input)
+		if c.err != "" {
+			assert.EqualError(t, err, c.err, c.input)
+			continue
+		}
 
-	res4, err4 := Rep("(set y (+ 1 7))")
-	assert.NoError(t, err4)
-	assert.Equal(t, "8", res4)
+		assert.NoError(t, err, c.input)
+		assert.Equal(t, c.output, res, "%s should return %s", c.input, c.output)
+	}
 }
 
-func TestRep_Let(t *testing.T) {
-	res1, err1 := Rep(`(let {z 9} z)`)
-	assert.NoError(t, err1)
-	assert.Equal(t, "9", res1)
+var setCases = []repCase{
+	{input: "(set x 2)", output: "2"},
+	{input: "x", output: "2"},
+	{input: "(+ 2 x)", output: "4"},
+	{input: "(set y (+ 1 7))", output: "8"},
+}
 
-	res2, err2 := Rep(`(let {z (+ 2 3)} (+ 1 z))`)
-	assert.NoError(t, err2)
-	assert.Equal(t, "6", res2)
+func TestRep_Set(t *testing.T) {
+	runRepCases(t, Rep, setCases)
+}
 
-	res3, err3 := Rep(`(let {p (+ 2 3) q (+ 2 p)} (+ p q))`)
-	assert.NoError(t, err3)
-	assert.Equal(t, "12", res3)
+var letCases = []repCase{
+	{input: "(let {z 9} z)", output: "9"},
+	{input: "(let {z (+ 2 3)} (+ 1 z))", output: "6"},
+	{input: "(let {p (+ 2 3) q (+ 2 p)} (+ p q))", output: "12"},
 }
 
-func TestRep_Outer(t *testing.T) {
-	res1, err1 := Rep(`(set a 4)`)
-	assert.NoError(t, err1)
-	assert.Equal(t, "4", res1)
+func TestRep_Let(t *testing.T) {
+	runRepCases(t, Rep, letCases)
+}
 
-	res2, err2 := Rep(`(let {q 9} q)`)
-	assert.NoError(t, err2)
-	assert.Equal(t, "9", res2)
+var letVectorCases = []repCase{
+	{input: "(let [z 9] z)", output: "9"},
+	{input: "(let [] 1)", output: "1"},
+	{input: "(let [a 1] )", output: "nil"},
+	{input: "(let [a 1 b (+ a 1)] b)", output: "2"},
+	{input: "(let [a 1 b (+ a 1) c (* b 10)] (+ a b c))", output: "23"},
+	{input: "(let [a 1 a (+ a 1)] a)", output: "2"},
+	{input: "(let [a 1] (set x0 a) (+ a 1))", output: "2"},
+	{input: "(let [[a b] (list 1 2)] (+ a b))", output: "3"},
+	{input: "(do 1 2 3)", output: "3"},
+	{input: "(do)", output: "nil"},
+	{input: "(let [a] a)", err: "let expects an even number of forms in bindings"},
+}
 
-	res3, err3 := Rep(`(let {q 9} a)`)
-	assert.NoError(t, err3)
-	assert.Equal(t, "4", res3)
+func TestRep_LetVector(t *testing.T) {
+	runRepCases(t, Rep, letVectorCases)
+}
 
-	res4, err4 := Rep(`(let {z 2} (let {q 9} a))`)
-	assert.NoError(t, err4)
-	assert.Equal(t, "4", res4)
+var outerCases = []repCase{
+	{input: "(set a 4)", output: "4"},
+	{input: "(let {q 9} q)", output: "9"},
+	{input: "(let {q 9} a)", output: "4"},
+	{input: "(let {z 2} (let {q 9} a))", output: "4"},
+	{input: "(let {z a} z)", output: "4"},
+}
 
-	res5, err5 := Rep(`(let {z a} z)`)
-	assert.NoError(t, err5)
-	assert.Equal(t, "4", res5)
+func TestRep_Outer(t *testing.T) {
+	runRepCases(t, Rep, outerCases)
 }
 
 var listCases = map[string]string{
@@ -248,43 +268,75 @@ func TestRep_Func(t *testing.T) {
 	}
 }
 
-func TestRep_Cjojures(t *testing.T) {
-	cases := []struct {
-		input  string
-		output string
-	}{
-		{"( ( (fn [a] (fn [b] (+ a b))) 5) 7)", "12"},
+var closureCases = []repCase{
+	{input: "( ( (fn [a] (fn [b] (+ a b))) 5) 7)", output: "12"},
 
-		{"(set gen-plus5 (fn [] (fn [b] (+ 5 b))))", "function"},
-		{"(set plus5 (gen-plus5))", "function"},
-		{"(plus5 7)", "12"},
+	{input: "(set gen-plus5 (fn [] (fn [b] (+ 5 b))))", output: "function"},
+	{input: "(set plus5 (gen-plus5))", output: "function"},
+	{input: "(plus5 7)", output: "12"},
 
-		{"(set gen-plusX (fn [x] (fn [b] (+ x b))))", "function"},
-		{"(set plus7 (gen-plusX 7))", "function"},
-		{"(plus7 8)", "15"},
-	}
+	{input: "(set gen-plusX (fn [x] (fn [b] (+ x b))))", output: "function"},
+	{input: "(set plus7 (gen-plusX 7))", output: "function"},
+	{input: "(plus7 8)", output: "15"},
+}
 
-	for _, c := range cases {
-		res, err := Rep(c.input)
-		assert.NoError(t, err)
-		assert.Equal(t, c.output, res, "%s should return %s", c.input, c.output)
-	}
+func TestRep_Cjojures(t *testing.T) {
+	runRepCases(t, Rep, closureCases)
+}
+
+var notCases = []repCase{
+	{input: "(not false)", output: "true"},
+	{input: "(not true)", output: "false"},
+	{input: `(not "a")`, output: "false"},
+	{input: "(not 0)", output: "false"},
 }
 
 func TestRep_Not(t *testing.T) {
-	cases := []struct {
-		input  string
-		output string
-	}{
-		{"(not false)", "true"},
-		{"(not true)", "false"},
-		{`(not "a")`, "false"},
-		{"(not 0)", "false"},
-	}
+	runRepCases(t, Rep, notCases)
+}
 
-	for _, c := range cases {
-		res, err := Rep(c.input)
-		assert.NoError(t, err)
-		assert.Equal(t, c.output, res, "%s should return %s", c.input, c.output)
-	}
+var conditionalCases = []repCase{
+	{input: "(if (= 1 2) 7 8)", output: "8"},
+	{input: "(if (list? (list)) 7 8)", output: "7"},
+
+	{input: "(and)", output: "true"},
+	{input: "(and 1 2)", output: "2"},
+	{input: "(and 1 nil 2)", output: "nil"},
+	{input: "(and false (undefined-fn))", output: "false"},
+	{input: "(or)", output: "nil"},
+	{input: "(or nil false)", output: "false"},
+	{input: "(or nil 2 3)", output: "2"},
+	{input: "(or 1 (undefined-fn))", output: "1"},
+
+	{input: "(cond)", output: "nil"},
+	{input: "(cond (= 1 2) 1 (= 1 1) 2)", output: "2"},
+	{input: "(cond false 1 :else 3)", output: "3"},
+	{input: "(cond false 1 nil 2)", output: "nil"},
+
+	{input: "(case 2 1 :one 2 :two)", output: ":two"},
+	{input: "(case (+ 1 2) 1 :one (2 3) :few :many)", output: ":few"},
+	{input: "(case 9 1 :one :many)", output: ":many"},
+	{input: `(case "a" "a" 1 "b" 2)`, output: "1"},
+	{input: "(case :k :k (+ 1 1))", output: "2"},
+	{input: "(case 9 1 :one)", err: "no matching clause: 9"},
+
+	{input: "(when true 1 2)", output: "2"},
+	{input: "(when nil 1 2)", output: "nil"},
+	{input: "(unless false 1 2)", output: "2"},
+	{input: "(unless 0 1 2)", output: "nil"},
+
+	{input: "(not nil)", output: "true"},
+}
+
+func TestRep_Conditionals(t *testing.T) {
+	runRepCases(t, Rep, conditionalCases)
+}
+
+func TestTruthy(t *testing.T) {
+	assert.False(t, Truthy(Nil{}))
+	assert.False(t, Truthy(False{}))
+	assert.True(t, Truthy(True{}))
+	assert.True(t, Truthy(Integer{Value: 0}))
+	assert.True(t, Truthy(String{Value: ""}))
+	assert.True(t, Truthy(List{}))
 }
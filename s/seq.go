@@ -0,0 +1,482 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+)
+
+// Seq is a sequence of items. Collections, strings and nil are sequences,
+// lazy sequences realize their items only when they are asked for, so
+// every method takes context of the evaluation doing so.
+type Seq interface {
+	Item
+	// First returns the first item, nil for an empty sequence
+	First(ctx context.Context) (Item, error)
+	// Rest returns sequence without the first item, empty for an empty one
+	Rest(ctx context.Context) (Seq, error)
+	// Empty returns true if there are no items
+	Empty(ctx context.Context) (bool, error)
+}
+
+func (self Nil) First(ctx context.Context) (Item, error)  { return Nil{}, nil }
+func (self Nil) Rest(ctx context.Context) (Seq, error)    { return List{Value: []Item{}}, nil }
+func (self Nil) Empty(ctx context.Context) (bool, error)  { return true, nil }
+func (self List) Empty(ctx context.Context) (bool, error) { return len(self.Value) == 0, nil }
+
+func (self List) First(ctx context.Context) (Item, error) {
+	if len(self.Value) == 0 {
+		return Nil{}, nil
+	}
+	return self.Value[0], nil
+}
+
+func (self List) Rest(ctx context.Context) (Seq, error) {
+	if len(self.Value) == 0 {
+		return self, nil
+	}
+	return List{Value: self.Value[1:]}, nil
+}
+
+func (self Vector) Empty(ctx context.Context) (bool, error) { return len(self.Value) == 0, nil }
+
+func (self Vector) First(ctx context.Context) (Item, error) {
+	return List{Value: self.Value}.First(ctx)
+}
+
+func (self Vector) Rest(ctx context.Context) (Seq, error) {
+	return List{Value: self.Value}.Rest(ctx)
+}
+
+// Hash is a sequence of [key value] vectors
+func (self Hash) Empty(ctx context.Context) (bool, error) { return len(self.Value) == 0, nil }
+
+func (self Hash) First(ctx context.Context) (Item, error) {
+	if len(self.Value) == 0 {
+		return Nil{}, nil
+	}
+	kv := self.Value[0]
+	return Vector{Value: []Item{kv.Key, kv.Value}}, nil
+}
+
+func (self Hash) Rest(ctx context.Context) (Seq, error) {
+	if len(self.Value) == 0 {
+		return List{Value: []Item{}}, nil
+	}
+	return Hash{Value: self.Value[1:]}, nil
+}
+
+// String is a sequence of one character strings
+func (self String) Empty(ctx context.Context) (bool, error) { return self.Value == "", nil }
+
+func (self String) First(ctx context.Context) (Item, error) {
+	if self.Value == "" {
+		return Nil{}, nil
+	}
+	_, size := utf8.DecodeRuneInString(self.Value)
+	return String{Value: self.Value[:size]}, nil
+}
+
+func (self String) Rest(ctx context.Context) (Seq, error) {
+	if self.Value == "" {
+		return List{Value: []Item{}}, nil
+	}
+	_, size := utf8.DecodeRuneInString(self.Value)
+	return String{Value: self.Value[size:]}, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Cons is a sequence made of an item followed by another sequence
+type Cons struct {
+	DefaultItem
+	Head Item
+	Tail Seq
+}
+
+func (self Cons) First(ctx context.Context) (Item, error) { return self.Head, nil }
+func (self Cons) Rest(ctx context.Context) (Seq, error)   { return self.Tail, nil }
+func (self Cons) Empty(ctx context.Context) (bool, error) { return false, nil }
+
+func (self Cons) Equal(i Item) Item {
+	return seqEqual(realizedOnly, self, i)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// LazySeq is a sequence computed on first use. Its body is called once
+// and must return a sequence, which the lazy sequence stands for.
+type LazySeq struct {
+	DefaultItem
+	mu   sync.Mutex
+	body func(ctx context.Context) (Item, error)
+	seq  Seq
+	// running is closed when the body being called returns
+	running chan struct{}
+}
+
+// NewLazySeq returns sequence realized by body
+func NewLazySeq(body func(ctx context.Context) (Item, error)) *LazySeq {
+	return &LazySeq{body: body}
+}
+
+// realizingKey is context key of lazy sequences realized by an evaluation
+type realizingKey struct{}
+
+type realizing struct {
+	seq    *LazySeq
+	parent *realizing
+}
+
+// realizedOnly is a done context, sequences can't be realized further
+// under it. Equal compares under it, as it has no evaluation to call
+// bodies under.
+var realizedOnly = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// realize calls the body unless it has been called already. Failed body
+// is called again next time, e.g. after a cancelled evaluation. Other
+// evaluations wait for a running body, the one running it gets an error.
+func (self *LazySeq) realize(ctx context.Context) (Seq, error) {
+	self.mu.Lock()
+	for self.running != nil {
+		running := self.running
+		self.mu.Unlock()
+
+		for r, _ := ctx.Value(realizingKey{}).(*realizing); r != nil; r = r.parent {
+			if r.seq == self {
+				return nil, fmt.Errorf("lazy-seq: realized recursively")
+			}
+		}
+		select {
+		case <-running:
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+		self.mu.Lock()
+	}
+
+	if self.body == nil {
+		self.mu.Unlock()
+		return self.seq, nil
+	}
+	if ctx.Err() != nil {
+		self.mu.Unlock()
+		return nil, context.Cause(ctx)
+	}
+
+	body := self.body
+	self.running = make(chan struct{})
+	self.mu.Unlock()
+
+	parent, _ := ctx.Value(realizingKey{}).(*realizing)
+	item, err := body(context.WithValue(ctx, realizingKey{}, &realizing{seq: self, parent: parent}))
+	seq, ok := item.(Seq)
+	if err == nil && !ok {
+		err = fmt.Errorf("lazy-seq: body expected sequence, got %s", typeName(item))
+	}
+
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	close(self.running)
+	self.running = nil
+	if err != nil {
+		return nil, err
+	}
+	self.seq, self.body = seq, nil
+	return seq, nil
+}
+
+func (self *LazySeq) First(ctx context.Context) (Item, error) {
+	seq, err := self.realize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return seq.First(ctx)
+}
+
+func (self *LazySeq) Rest(ctx context.Context) (Seq, error) {
+	seq, err := self.realize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return seq.Rest(ctx)
+}
+
+func (self *LazySeq) Empty(ctx context.Context) (bool, error) {
+	seq, err := self.realize(ctx)
+	if err != nil {
+		return false, err
+	}
+	return seq.Empty(ctx)
+}
+
+func (self *LazySeq) Equal(i Item) Item {
+	if self == i {
+		return True{}
+	}
+	return seqEqual(realizedOnly, self, i)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// isLazy returns true for sequences which are not collections
+func isLazy(item Item) bool {
+	switch item.(type) {
+	case *LazySeq, Cons:
+		return true
+	}
+	return false
+}
+
+// seqEqual compares items of a lazy sequence with another sequence,
+// other items are compared by Equal. Sequences which can't be realized
+// under ctx are not equal.
+func seqEqual(ctx context.Context, left Item, right Item) Item {
+	if !isLazy(left) && !isLazy(right) {
+		return left.Equal(right)
+	}
+
+	a, ok := left.(Seq)
+	b, ok2 := right.(Seq)
+	if !ok || !ok2 || left.IsString() || right.IsString() || left.IsHash() || right.IsHash() {
+		return False{}
+	}
+
+	for {
+		aEmpty, err := a.Empty(ctx)
+		if err != nil {
+			return False{}
+		}
+		bEmpty, err := b.Empty(ctx)
+		if err != nil {
+			return False{}
+		}
+		if aEmpty || bEmpty {
+			if aEmpty && bEmpty {
+				return True{}
+			}
+			return False{}
+		}
+
+		x, err := a.First(ctx)
+		if err != nil {
+			return False{}
+		}
+		y, err := b.First(ctx)
+		if err != nil {
+			return False{}
+		}
+		if !seqEqual(ctx, x, y).IsTrue() {
+			return False{}
+		}
+
+		if a, err = a.Rest(ctx); err != nil {
+			return False{}
+		}
+		if b, err = b.Rest(ctx); err != nil {
+			return False{}
+		}
+	}
+}
+
+// realize returns all items of seq, the collection limit applies to
+// lazy sequences
+func realize(ctx context.Context, seq Seq) ([]Item, error) {
+	switch v := seq.(type) {
+	case List:
+		return v.Value, nil
+	case Vector:
+		return v.Value, nil
+	case Nil:
+		return nil, nil
+	}
+
+	var items []Item
+	for {
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+
+		empty, err := seq.Empty(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if empty {
+			return items, nil
+		}
+
+		item, err := seq.First(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if err := checkSize(ctx, len(items)); err != nil {
+			return nil, err
+		}
+
+		if seq, err = seq.Rest(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// count returns number of items of seq
+func count(ctx context.Context, seq Seq) (int, error) {
+	switch v := seq.(type) {
+	case List:
+		return len(v.Value), nil
+	case Vector:
+		return len(v.Value), nil
+	case Hash:
+		return len(v.Value), nil
+	case String:
+		return utf8.RuneCountInString(v.Value), nil
+	}
+
+	items, err := realize(ctx, seq)
+	return len(items), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// rangeSeq returns lazy sequence of numbers from start by step, up to end
+// unless it is infinite
+func rangeSeq(start, end, step int64, infinite bool) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		if !infinite && (step > 0 && start >= end || step < 0 && start <= end || step == 0) {
+			return List{Value: []Item{}}, nil
+		}
+		return Cons{Head: Integer{Value: start}, Tail: rangeSeq(start+step, end, step, infinite)}, nil
+	})
+}
+
+// iterateSeq returns lazy sequence of x, (f x), (f (f x))...
+func iterateSeq(fn Func, x Item) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		next := NewLazySeq(func(ctx context.Context) (Item, error) {
+			y, err := apply(ctx, fn, []Item{x})
+			if err != nil {
+				return nil, err
+			}
+			return iterateSeq(fn, y), nil
+		})
+		return Cons{Head: x, Tail: next}, nil
+	})
+}
+
+// repeatSeq returns lazy sequence of n items x, infinite when n is negative
+func repeatSeq(x Item, n int64) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		if n == 0 {
+			return List{Value: []Item{}}, nil
+		}
+		return Cons{Head: x, Tail: repeatSeq(x, n-1)}, nil
+	})
+}
+
+// cycleSeq returns lazy sequence repeating items of coll forever
+func cycleSeq(coll Seq) Seq {
+	var next func(seq Seq) Seq
+	next = func(seq Seq) Seq {
+		return NewLazySeq(func(ctx context.Context) (Item, error) {
+			empty, err := seq.Empty(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if empty {
+				// Start over, unless coll itself is empty
+				if empty, err := coll.Empty(ctx); err != nil || empty {
+					return List{Value: []Item{}}, err
+				}
+				seq = coll
+			}
+
+			first, err := seq.First(ctx)
+			if err != nil {
+				return nil, err
+			}
+			rest, err := seq.Rest(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return Cons{Head: first, Tail: next(rest)}, nil
+		})
+	}
+	return next(coll)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// initSeqs sets up sequences, they are part of core
+func (e *Env) initSeqs() {
+	e.DefineBuiltin("seq", Signature{Params: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		seq := args[0].(Seq)
+		empty, err := seq.Empty(ctx)
+		if err != nil || empty {
+			return Nil{}, err
+		}
+		return seq, nil
+	})
+
+	e.DefineBuiltin("first", Signature{Params: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		return args[0].(Seq).First(ctx)
+	})
+
+	e.DefineBuiltin("rest", Signature{Params: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		return args[0].(Seq).Rest(ctx)
+	})
+
+	e.DefineBuiltin("cons", Signature{Params: []Type{TypeAny, TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		return Cons{Head: args[0], Tail: args[1].(Seq)}, nil
+	})
+
+	e.DefineBuiltin("lazy-seq*", Signature{Params: []Type{TypeFunc}}, func(ctx context.Context, args []Item) (Item, error) {
+		body := args[0].(Func)
+		return NewLazySeq(func(ctx context.Context) (Item, error) {
+			return apply(ctx, body, nil)
+		}), nil
+	})
+
+	e.DefineBuiltin("range", Signature{Optional: []Type{TypeInteger, TypeInteger, TypeInteger}}, func(ctx context.Context, args []Item) (Item, error) {
+		bounds := make([]int64, len(args))
+		for i, arg := range args {
+			bounds[i] = arg.(Integer).Value
+		}
+
+		switch len(bounds) {
+		case 0:
+			return rangeSeq(0, 0, 1, true), nil
+		case 1:
+			return rangeSeq(0, bounds[0], 1, false), nil
+		case 2:
+			return rangeSeq(bounds[0], bounds[1], 1, false), nil
+		default:
+			return rangeSeq(bounds[0], bounds[1], bounds[2], false), nil
+		}
+	})
+
+	e.DefineBuiltin("iterate", Signature{Params: []Type{TypeFunc, TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		return iterateSeq(args[0].(Func), args[1]), nil
+	})
+
+	e.DefineBuiltin("repeat", Signature{Params: []Type{TypeAny}, Optional: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) == 1 {
+			return repeatSeq(args[0], -1), nil
+		}
+
+		n, ok := args[0].(Integer)
+		if !ok {
+			return nil, fmt.Errorf("repeat: argument 1 expected integer, got %s", typeName(args[0]))
+		}
+		return repeatSeq(args[1], max(n.Value, 0)), nil
+	})
+
+	e.DefineBuiltin("cycle", Signature{Params: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		return cycleSeq(args[0].(Seq)), nil
+	})
+}
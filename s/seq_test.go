@@ -0,0 +1,163 @@
+package s
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeq(t *testing.T) {
+	for name, backend := range backends {
+		in := NewInterpreter(Options{Backend: backend})
+
+		cases := []struct {
+			input  string
+			output string
+			err    string
+		}{
+			{input: "(count [1 2 3])", output: "3"},
+			{input: "(count {:a 1 :b 2})", output: "2"},
+			{input: `(count "héllo")`, output: "5"},
+			{input: "(count (range 5))", output: "5"},
+			{input: "(count 1)", err: "count: argument 1 expected sequence, got integer"},
+			{input: "(empty? [])", output: "true"},
+			{input: `(empty? "")`, output: "true"},
+			{input: "(empty? (range))", output: "false"},
+			{input: "(first [1 2])", output: "1"},
+			{input: "(rest [1 2])", output: "(2)"},
+			{input: "(first {:a 1})", output: "[:a 1]"},
+			{input: `(first (rest "abc"))`, output: `"b"`},
+			{input: "(first nil)", output: "nil"},
+			{input: "(seq [])", output: "nil"},
+			{input: "(cons 0 [1 2])", output: "(0 1 2)"},
+
+			{input: "(range 3)", output: "(0 1 2)"},
+			{input: "(range 1 10 4)", output: "(1 5 9)"},
+			{input: "(range 3 0 (- 0 1))", output: "(3 2 1)"},
+			{input: "(first (rest (rest (range))))", output: "2"},
+			{input: "(repeat 3 :x)", output: "(:x :x :x)"},
+			{input: "(first (rest (repeat :x)))", output: ":x"},
+			{input: "(set c (cycle [1 2]))", output: "(" + strings.Repeat("1 2 ", maxPrintedItems/2) + "...)"},
+			{input: "(first (rest (rest c)))", output: "1"},
+			{input: "(cycle [])", output: "()"},
+			{input: "(first (rest (rest (rest (iterate (fn [x] (* x 2)) 1)))))", output: "8"},
+			{input: "(= (range 3) (list 0 1 2))", output: "true"},
+			{input: "(= [0 1 2] (range 3))", output: "true"},
+			{input: "(= (range 3) (range 4))", output: "false"},
+
+			// lazy-seq realizes its body once, when consumed
+			{input: "(set calls (atom 0))", output: "#<Atom 0>"},
+			{input: "(set nums (fn [n] (lazy-seq (swap! calls (fn [c] (+ c 1))) (cons n (nums (+ n 1))))))", output: "function"},
+			{input: "(set s (nums 0))", err: ""},
+			{input: "@calls", output: "0"},
+			{input: "(first (rest (rest s)))", output: "2"},
+			{input: "@calls", output: "3"},
+			{input: "(first (rest (rest s)))", output: "2"},
+			{input: "@calls", output: "3"},
+			{input: "(first (lazy-seq 1))", err: "lazy-seq: body expected sequence, got integer"},
+			{input: "(do (set xs (lazy-seq (cons 1 (rest xs)))) nil)", output: "nil"},
+			{input: "(first xs)", err: "lazy-seq: realized recursively"},
+			{input: "(first xs)", err: "lazy-seq: realized recursively"},
+		}
+
+		for _, c := range cases {
+			if c.input == "(set s (nums 0))" {
+				// Printing would realize the sequence
+				_, err := in.EvalString(c.input)
+				assert.NoError(t, err, name)
+				continue
+			}
+
+			res, err := in.Rep(c.input)
+			if c.err != "" {
+				assert.EqualError(t, err, c.err, "%s: %s", name, c.input)
+				continue
+			}
+			assert.NoError(t, err, "%s: %s", name, c.input)
+			assert.Equal(t, c.output, res, "%s: %s", name, c.input)
+		}
+	}
+}
+
+func TestSeq_Limits(t *testing.T) {
+	in := NewInterpreter(Options{Limits: Limits{MaxCollection: 100}})
+
+	_, err := in.EvalString("(count (range))")
+	var limit *LimitExceeded
+	if assert.True(t, errors.As(err, &limit)) {
+		assert.Equal(t, "collection", limit.Limit)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = realize(ctx, rangeSeq(0, 0, 1, true))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Printing realizes sequences within limits of the evaluation
+	in = NewInterpreter(Options{Limits: Limits{MaxSteps: 50}})
+	_, err = in.Rep("(map (fn [x] (+ x 1)) (range 5000))")
+	if assert.True(t, errors.As(err, &limit)) {
+		assert.Equal(t, "steps", limit.Limit)
+	}
+
+	in = NewInterpreter(Options{})
+	_, err = in.EvalString("(set loop (fn [n] (loop n)))")
+	assert.NoError(t, err)
+	_, err = in.Rep("(map loop (range 1))")
+	if assert.True(t, errors.As(err, &limit)) {
+		assert.Equal(t, "depth", limit.Limit)
+	}
+}
+
+func TestSeq_RealizedOnly(t *testing.T) {
+	called := false
+	seq := NewLazySeq(func(ctx context.Context) (Item, error) {
+		called = true
+		return List{Value: []Item{Integer{Value: 1}}}, nil
+	})
+
+	// Without an evaluation bodies are not called
+	assert.True(t, seq.Equal(List{Value: []Item{Integer{Value: 1}}}).IsFalse())
+	assert.True(t, seq.Equal(seq).IsTrue())
+	str, err := print(Cons{Head: Integer{Value: 0}, Tail: seq})
+	assert.NoError(t, err)
+	assert.Equal(t, "(0 ...)", str)
+	assert.False(t, called)
+
+	_, err = seq.First(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, seq.Equal(List{Value: []Item{Integer{Value: 1}}}).IsTrue())
+	str, err = print(seq)
+	assert.NoError(t, err)
+	assert.Equal(t, "(1)", str)
+}
+
+func TestSeq_RealizeConcurrent(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	seq := NewLazySeq(func(ctx context.Context) (Item, error) {
+		calls.Add(1)
+		<-release
+		return List{Value: []Item{Integer{Value: 1}}}, nil
+	})
+
+	// Other evaluations wait for the running body rather than failing
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first, err := seq.First(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, Integer{Value: 1}, first)
+		}()
+	}
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+}
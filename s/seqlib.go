@@ -0,0 +1,716 @@
+package s
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// emptyList is returned by sequences which run out of items
+var emptyList = List{Value: []Item{}}
+
+// uncons splits seq into its first item and the rest, ok is false when
+// seq is empty
+func uncons(ctx context.Context, seq Seq) (first Item, rest Seq, ok bool, err error) {
+	empty, err := seq.Empty(ctx)
+	if err != nil || empty {
+		return nil, nil, false, err
+	}
+	if first, err = seq.First(ctx); err != nil {
+		return nil, nil, false, err
+	}
+	if rest, err = seq.Rest(ctx); err != nil {
+		return nil, nil, false, err
+	}
+	return first, rest, true, nil
+}
+
+// itemKey returns a comparable key of item, equal items have equal keys.
+// Lazy sequences are realized under ctx and keyed as lists.
+func itemKey(ctx context.Context, item Item) (any, error) {
+	switch v := item.(type) {
+	case Symbol:
+		// Literals without a handle get the key of interned names
+		return NewSymbol(v.Value), nil
+	case Keyword:
+		return NewKeyword(v.Value), nil
+	case Nil, True, False, Integer, Float, String:
+		return v, nil
+	case *Atom, *Future, *Channel:
+		return v, nil
+	case Func:
+		// Func values point to their closure, copies share it
+		return funcKey{*(*unsafe.Pointer)(unsafe.Pointer(&v.Value))}, nil
+	case GoValue:
+		if reflect.ValueOf(v.Value).Comparable() {
+			return goValueKey{v.Value}, nil
+		}
+		// Other Go values are not equal to anything
+		return new(goValueKey), nil
+	case ExInfo:
+		data, err := itemKey(ctx, v.Data)
+		if err != nil {
+			return nil, err
+		}
+		return exInfoKey{v.Message, data}, nil
+	}
+
+	item, err := realizeAll(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	str, err := print(item)
+	if err != nil {
+		return nil, err
+	}
+	return typeName(item) + ":" + str, nil
+}
+
+type funcKey struct{ closure unsafe.Pointer }
+
+type goValueKey struct{ value any }
+
+type exInfoKey struct {
+	message string
+	data    any
+}
+
+// realizeAll returns item with lazy sequences in it realized into lists
+func realizeAll(ctx context.Context, item Item) (Item, error) {
+	var items []Item
+	var err error
+
+	switch v := item.(type) {
+	case Cons, *LazySeq:
+		if items, err = realize(ctx, v.(Seq)); err != nil {
+			return nil, err
+		}
+		return realizeAll(ctx, List{Value: items})
+
+	case List:
+		items, err = realizeItems(ctx, v.Value)
+		return List{Value: items}, err
+
+	case Vector:
+		items, err = realizeItems(ctx, v.Value)
+		return Vector{Value: items}, err
+
+	case Hash:
+		kvs := make([]KeyValue, len(v.Value))
+		for i, kv := range v.Value {
+			if kvs[i].Key, err = realizeAll(ctx, kv.Key); err != nil {
+				return nil, err
+			}
+			if kvs[i].Value, err = realizeAll(ctx, kv.Value); err != nil {
+				return nil, err
+			}
+		}
+		return Hash{Value: kvs}, nil
+	}
+	return item, nil
+}
+
+func realizeItems(ctx context.Context, items []Item) ([]Item, error) {
+	out := make([]Item, len(items))
+	for i, item := range items {
+		var err error
+		if out[i], err = realizeAll(ctx, item); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// compareItems orders two atoms of the same type
+func compareItems(a Item, b Item) (int, error) {
+	switch x := a.(type) {
+	case Integer:
+		if y, ok := b.(Integer); ok {
+			return cmp.Compare(x.Value, y.Value), nil
+		}
+		if y, ok := b.(Float); ok {
+			return compareFloats(float64(x.Value), y.Value), nil
+		}
+	case Float:
+		if y, ok := b.(Float); ok {
+			return compareFloats(x.Value, y.Value), nil
+		}
+		if y, ok := b.(Integer); ok {
+			return compareFloats(x.Value, float64(y.Value)), nil
+		}
+	case String:
+		if y, ok := b.(String); ok {
+			return strings.Compare(x.Value, y.Value), nil
+		}
+	case Keyword:
+		if y, ok := b.(Keyword); ok {
+			return strings.Compare(x.Value, y.Value), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s and %s", typeName(a), typeName(b))
+}
+
+func compareFloats(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func mapSeq(fn Func, seqs []Seq) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		args := make([]Item, len(seqs))
+		rests := make([]Seq, len(seqs))
+		for i, seq := range seqs {
+			first, rest, ok, err := uncons(ctx, seq)
+			if err != nil || !ok {
+				return emptyList, err
+			}
+			args[i], rests[i] = first, rest
+		}
+
+		value, err := apply(ctx, fn, args)
+		if err != nil {
+			return nil, err
+		}
+		return Cons{Head: value, Tail: mapSeq(fn, rests)}, nil
+	})
+}
+
+// filterSeq keeps items for which fn returns keep
+func filterSeq(fn Func, seq Seq, keep bool) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		for {
+			first, rest, ok, err := uncons(ctx, seq)
+			if err != nil || !ok {
+				return emptyList, err
+			}
+
+			value, err := apply(ctx, fn, []Item{first})
+			if err != nil {
+				return nil, err
+			}
+			if Truthy(value) == keep {
+				return Cons{Head: first, Tail: filterSeq(fn, rest, keep)}, nil
+			}
+			seq = rest
+		}
+	})
+}
+
+func concatSeq(seqs []Seq) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		for len(seqs) > 0 {
+			first, rest, ok, err := uncons(ctx, seqs[0])
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				tail := append([]Seq{rest}, seqs[1:]...)
+				return Cons{Head: first, Tail: concatSeq(tail)}, nil
+			}
+			seqs = seqs[1:]
+		}
+		return emptyList, nil
+	})
+}
+
+func takeSeq(n int64, seq Seq) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		if n <= 0 {
+			return emptyList, nil
+		}
+
+		first, rest, ok, err := uncons(ctx, seq)
+		if err != nil || !ok {
+			return emptyList, err
+		}
+		return Cons{Head: first, Tail: takeSeq(n-1, rest)}, nil
+	})
+}
+
+func dropSeq(n int64, seq Seq) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		for ; n > 0; n-- {
+			_, rest, ok, err := uncons(ctx, seq)
+			if err != nil || !ok {
+				return emptyList, err
+			}
+			seq = rest
+		}
+		return seq, nil
+	})
+}
+
+func takeWhileSeq(fn Func, seq Seq) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		first, rest, ok, err := uncons(ctx, seq)
+		if err != nil || !ok {
+			return emptyList, err
+		}
+
+		value, err := apply(ctx, fn, []Item{first})
+		if err != nil || !Truthy(value) {
+			return emptyList, err
+		}
+		return Cons{Head: first, Tail: takeWhileSeq(fn, rest)}, nil
+	})
+}
+
+// partitionSeq returns lists of n items starting step items apart, an
+// incomplete last list is left out
+func partitionSeq(n int64, step int64, seq Seq) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		part := make([]Item, 0, n)
+		for s := seq; int64(len(part)) < n; {
+			first, rest, ok, err := uncons(ctx, s)
+			if err != nil || !ok {
+				return emptyList, err
+			}
+			part = append(part, first)
+			s = rest
+		}
+
+		return Cons{Head: List{Value: part}, Tail: partitionSeq(n, step, dropSeq(step, seq))}, nil
+	})
+}
+
+// distinctSeq leaves out items seen before
+func distinctSeq(seq Seq, seen map[any]bool) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		for {
+			first, rest, ok, err := uncons(ctx, seq)
+			if err != nil || !ok {
+				return emptyList, err
+			}
+
+			key, err := itemKey(ctx, first)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[key] {
+				seen[key] = true
+				return Cons{Head: first, Tail: distinctSeq(rest, seen)}, nil
+			}
+			seq = rest
+		}
+	})
+}
+
+func interleaveSeq(seqs []Seq) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		firsts := make([]Item, len(seqs))
+		rests := make([]Seq, len(seqs))
+		for i, seq := range seqs {
+			first, rest, ok, err := uncons(ctx, seq)
+			if err != nil || !ok {
+				return emptyList, err
+			}
+			firsts[i], rests[i] = first, rest
+		}
+
+		var tail Seq = interleaveSeq(rests)
+		for i := len(firsts) - 1; i >= 0; i-- {
+			tail = Cons{Head: firsts[i], Tail: tail}
+		}
+		return tail, nil
+	})
+}
+
+// conj adds items to coll the way it grows best: to the end of vectors,
+// as [key value] pairs to hashes and to the front of other sequences
+func conj(ctx context.Context, coll Item, items []Item) (Item, error) {
+	if err := checkSize(ctx, len(items)); err != nil {
+		return nil, err
+	}
+
+	switch coll := coll.(type) {
+	case Vector:
+		values := append(append([]Item{}, coll.Value...), items...)
+		if err := checkSize(ctx, len(values)); err != nil {
+			return nil, err
+		}
+		return Vector{Value: values}, nil
+
+	case Hash:
+		for _, item := range items {
+			pair, err := seqItems(item)
+			if err != nil || len(pair) != 2 {
+				return nil, fmt.Errorf("expected [key value] pairs for a hash, got %s", typeName(item))
+			}
+			coll = hashAssoc(coll, pair[0], pair[1])
+		}
+		if err := checkSize(ctx, len(coll.Value)); err != nil {
+			return nil, err
+		}
+		return coll, nil
+
+	case List:
+		values := make([]Item, 0, len(items)+len(coll.Value))
+		for i := len(items) - 1; i >= 0; i-- {
+			values = append(values, items[i])
+		}
+		values = append(values, coll.Value...)
+		if err := checkSize(ctx, len(values)); err != nil {
+			return nil, err
+		}
+		return List{Value: values}, nil
+
+	case Nil, Cons, *LazySeq:
+		seq := coll.(Seq)
+		for _, item := range items {
+			seq = Cons{Head: item, Tail: seq}
+		}
+		return seq, nil
+
+	default:
+		return nil, fmt.Errorf("expected collection, got %s", typeName(coll))
+	}
+}
+
+// reduceSeq calls fn with acc and each item of seq in turn, the result
+// becoming the next acc. A Reduced result stops it early.
+func reduceSeq(ctx context.Context, fn Func, acc Item, seq Seq) (Item, error) {
+	for {
+		first, rest, ok, err := uncons(ctx, seq)
+		if err != nil || !ok {
+			return acc, err
+		}
+
+		if acc, err = apply(ctx, fn, []Item{acc, first}); err != nil {
+			return nil, err
+		}
+		if r, ok := acc.(Reduced); ok {
+			return r.Value, nil
+		}
+		seq = rest
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// initSeqLib sets up functions working over sequences, they are part of
+// core
+func (e *Env) initSeqLib() {
+	fnSeq := Signature{Params: []Type{TypeFunc, TypeSeq}}
+	// Called without a collection these return transducers
+	fnXform := Signature{Params: []Type{TypeFunc}, Optional: []Type{TypeSeq}}
+	nXform := Signature{Params: []Type{TypeInteger}, Optional: []Type{TypeSeq}}
+
+	e.DefineBuiltin("conj", Signature{Params: []Type{TypeSeq}, Rest: TypeAny}, func(ctx context.Context, args []Item) (Item, error) {
+		coll, err := conj(ctx, args[0], args[1:])
+		if err != nil {
+			return nil, fmt.Errorf("conj: %w", err)
+		}
+		return coll, nil
+	})
+
+	e.DefineBuiltin("concat", Signature{Rest: TypeSeq}, func(ctx context.Context, args []Item) (Item, error) {
+		seqs := make([]Seq, len(args))
+		for i, arg := range args {
+			seqs[i] = arg.(Seq)
+		}
+		return concatSeq(seqs), nil
+	})
+
+	e.DefineBuiltin("nth", Signature{Params: []Type{TypeSeq, TypeInteger}, Optional: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		n := args[1].(Integer).Value
+		missing := func() (Item, error) {
+			if len(args) > 2 {
+				return args[2], nil
+			}
+			return nil, fmt.Errorf("nth: index %d out of range", n)
+		}
+		if n < 0 {
+			return missing()
+		}
+
+		switch coll := args[0].(type) {
+		case List:
+			if n < int64(len(coll.Value)) {
+				return coll.Value[n], nil
+			}
+			return missing()
+		case Vector:
+			if n < int64(len(coll.Value)) {
+				return coll.Value[n], nil
+			}
+			return missing()
+		}
+
+		seq := args[0].(Seq)
+		for i := int64(0); ; i++ {
+			first, rest, ok, err := uncons(ctx, seq)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return missing()
+			}
+			if i == n {
+				return first, nil
+			}
+			seq = rest
+		}
+	})
+
+	e.DefineBuiltin("map", Signature{Params: []Type{TypeFunc}, Rest: TypeSeq}, func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) == 1 {
+			return mapXform(args[0].(Func)), nil
+		}
+
+		seqs := make([]Seq, len(args)-1)
+		for i, arg := range args[1:] {
+			seqs[i] = arg.(Seq)
+		}
+		return mapSeq(args[0].(Func), seqs), nil
+	})
+
+	e.DefineBuiltin("filter", fnXform, func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) == 1 {
+			return filterXform(args[0].(Func), true), nil
+		}
+		return filterSeq(args[0].(Func), args[1].(Seq), true), nil
+	})
+
+	e.DefineBuiltin("remove", fnXform, func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) == 1 {
+			return filterXform(args[0].(Func), false), nil
+		}
+		return filterSeq(args[0].(Func), args[1].(Seq), false), nil
+	})
+
+	e.DefineBuiltin("reduce", Signature{Params: []Type{TypeFunc, TypeAny}, Optional: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		fn := args[0].(Func)
+
+		var acc Item
+		var seq Seq
+		if len(args) == 3 {
+			acc, seq = args[1], args[2].(Seq)
+		} else {
+			var ok bool
+			if seq, ok = args[1].(Seq); !ok {
+				return nil, fmt.Errorf("reduce: argument 2 expected sequence, got %s", typeName(args[1]))
+			}
+
+			first, rest, ok, err := uncons(ctx, seq)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				// Reducing nothing calls fn without arguments
+				return apply(ctx, fn, nil)
+			}
+			acc, seq = first, rest
+		}
+		return reduceSeq(ctx, fn, acc, seq)
+	})
+
+	e.DefineBuiltin("take", nXform, func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) == 1 {
+			return takeXform(args[0].(Integer).Value), nil
+		}
+		return takeSeq(args[0].(Integer).Value, args[1].(Seq)), nil
+	})
+
+	e.DefineBuiltin("drop", nXform, func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) == 1 {
+			return dropXform(args[0].(Integer).Value), nil
+		}
+		return dropSeq(args[0].(Integer).Value, args[1].(Seq)), nil
+	})
+
+	e.DefineBuiltin("take-while", fnXform, func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) == 1 {
+			return takeWhileXform(args[0].(Func)), nil
+		}
+		return takeWhileSeq(args[0].(Func), args[1].(Seq)), nil
+	})
+
+	e.DefineBuiltin("partition", Signature{Params: []Type{TypeInteger, TypeAny}, Optional: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		n := args[0].(Integer).Value
+		step, coll := n, args[1]
+		if len(args) == 3 {
+			s, ok := args[1].(Integer)
+			if !ok {
+				return nil, fmt.Errorf("partition: argument 2 expected integer, got %s", typeName(args[1]))
+			}
+			step, coll = s.Value, args[2]
+		}
+
+		seq, ok := coll.(Seq)
+		if !ok {
+			return nil, fmt.Errorf("partition: argument %d expected sequence, got %s", len(args), typeName(coll))
+		}
+		if n <= 0 || step <= 0 {
+			return nil, fmt.Errorf("partition: size and step must be positive")
+		}
+		return partitionSeq(n, step, seq), nil
+	})
+
+	e.DefineBuiltin("group-by", fnSeq, func(ctx context.Context, args []Item) (Item, error) {
+		items, err := realize(ctx, args[1].(Seq))
+		if err != nil {
+			return nil, err
+		}
+
+		fn := args[0].(Func)
+		groups := Hash{}
+		index := make(map[any]int)
+		for _, item := range items {
+			key, err := apply(ctx, fn, []Item{item})
+			if err != nil {
+				return nil, err
+			}
+
+			k, err := itemKey(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			i, ok := index[k]
+			if !ok {
+				i = len(groups.Value)
+				index[k] = i
+				groups = groups.Add(KeyValue{Key: key, Value: Vector{}})
+			}
+			group := groups.Value[i].Value.(Vector)
+			groups.Value[i].Value = group.Add(item)
+		}
+		return groups, nil
+	})
+
+	e.DefineBuiltin("frequencies", Signature{Params: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		items, err := realize(ctx, args[0].(Seq))
+		if err != nil {
+			return nil, err
+		}
+
+		counts := Hash{}
+		index := make(map[any]int)
+		for _, item := range items {
+			key, err := itemKey(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+			i, ok := index[key]
+			if !ok {
+				i = len(counts.Value)
+				index[key] = i
+				counts = counts.Add(KeyValue{Key: item, Value: Integer{Value: 0}})
+			}
+			n := counts.Value[i].Value.(Integer)
+			counts.Value[i].Value = Integer{Value: n.Value + 1}
+		}
+		return counts, nil
+	})
+
+	e.DefineBuiltin("distinct", Signature{Optional: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) == 0 {
+			return distinctXform(), nil
+		}
+		return distinctSeq(args[0].(Seq), make(map[any]bool)), nil
+	})
+
+	e.DefineBuiltin("sort", Signature{Params: []Type{TypeAny}, Optional: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		coll := args[len(args)-1]
+		seq, ok := coll.(Seq)
+		if !ok {
+			return nil, fmt.Errorf("sort: argument %d expected sequence, got %s", len(args), typeName(coll))
+		}
+
+		order := compareItems
+		if len(args) == 2 {
+			fn, ok := args[0].(Func)
+			if !ok {
+				return nil, fmt.Errorf("sort: argument 1 expected function, got %s", typeName(args[0]))
+			}
+
+			// Comparators return a number like compare, or true when
+			// the first item goes first
+			order = func(a Item, b Item) (int, error) {
+				res, err := apply(ctx, fn, []Item{a, b})
+				if err != nil {
+					return 0, err
+				}
+				switch v := res.(type) {
+				case Integer:
+					return int(max(-1, min(1, v.Value))), nil
+				case True:
+					return -1, nil
+				case False, Nil:
+					if res, err = apply(ctx, fn, []Item{b, a}); err != nil || !Truthy(res) {
+						return 0, err
+					}
+					return 1, nil
+				default:
+					return 0, fmt.Errorf("comparator returned %s", typeName(res))
+				}
+			}
+		}
+
+		items, err := realize(ctx, seq)
+		if err != nil {
+			return nil, err
+		}
+		sorted := append([]Item{}, items...)
+
+		var sortErr error
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if sortErr != nil {
+				return false
+			}
+			c, err := order(sorted[i], sorted[j])
+			if err != nil {
+				sortErr = err
+			}
+			return c < 0
+		})
+		if sortErr != nil {
+			return nil, fmt.Errorf("sort: %w", sortErr)
+		}
+		return List{Value: sorted}, nil
+	})
+
+	e.DefineBuiltin("reverse", Signature{Params: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		items, err := realize(ctx, args[0].(Seq))
+		if err != nil {
+			return nil, err
+		}
+
+		reversed := make([]Item, len(items))
+		for i, item := range items {
+			reversed[len(items)-1-i] = item
+		}
+		return List{Value: reversed}, nil
+	})
+
+	e.DefineBuiltin("interleave", Signature{Params: []Type{TypeSeq}, Rest: TypeSeq}, func(ctx context.Context, args []Item) (Item, error) {
+		seqs := make([]Seq, len(args))
+		for i, arg := range args {
+			seqs[i] = arg.(Seq)
+		}
+		return interleaveSeq(seqs), nil
+	})
+
+	e.DefineBuiltin("zip", Signature{Params: []Type{TypeSeq}, Rest: TypeSeq}, func(ctx context.Context, args []Item) (Item, error) {
+		seqs := make([]Seq, len(args))
+		for i, arg := range args {
+			seqs[i] = arg.(Seq)
+		}
+
+		vector := Func{Value: func(ctx context.Context, args []Item) (Item, error) {
+			return Vector{Value: args}, nil
+		}}
+		return mapSeq(vector, seqs), nil
+	})
+}
@@ -0,0 +1,114 @@
+package s
+
+import (
+	"context"
+
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeqLib(t *testing.T) {
+	for name, backend := range backends {
+		in := NewInterpreter(Options{Backend: backend})
+
+		cases := []struct {
+			input  string
+			output string
+			err    string
+		}{
+			{input: "(conj [1 2] 3 4)", output: "[1 2 3 4]"},
+			{input: "(conj (list 1 2) 3 4)", output: "(4 3 1 2)"},
+			{input: "(conj nil 1)", output: "(1)"},
+			{input: "(conj {:a 1} [:a 2] [:b 3])", output: "{:a 2 :b 3}"},
+			{input: "(conj {:a 1} 2)", err: "conj: expected [key value] pairs for a hash, got integer"},
+			{input: "(first (conj (range) :x))", output: ":x"},
+			{input: "(concat [1] (list 2) nil {:a 3})", output: "(1 2 [:a 3])"},
+			{input: "(nth [1 2 3] 1)", output: "2"},
+			{input: `(nth "abc" 2)`, output: `"c"`},
+			{input: "(nth (range) 100)", output: "100"},
+			{input: "(nth [1] 5)", err: "nth: index 5 out of range"},
+			{input: "(nth [1] 5 :none)", output: ":none"},
+
+			{input: "(map (fn [x] (* x x)) [1 2 3])", output: "(1 4 9)"},
+			{input: "(map + [1 2 3] (range))", output: "(1 3 5)"},
+			{input: "(map (fn [kv] (first kv)) {:a 1 :b 2})", output: "(:a :b)"},
+
+			// Keywords and hashes work as functions
+			{input: "(map :a [{:a 1} {:a 2}])", output: "(1 2)"},
+			{input: "(map {1 :one 2 :two} [1 2 3])", output: "(:one :two nil)"},
+			{input: "(filter :ok [{:ok true} {:ok false}])", output: "({:ok true})"},
+			{input: "(remove {2 true} [1 2 3])", output: "(1 3)"},
+			{input: "(into [] (map :a) [{:a 1}])", output: "[1]"},
+			{input: "(reduce :a [1])", output: "1"},
+			{input: "(map 1 [1])", err: "map: argument 1 expected function, got integer"},
+			{input: "(filter (fn [x] (> x 1)) (list 1 2 3))", output: "(2 3)"},
+			{input: "(remove (fn [x] (> x 1)) (list 1 2 3))", output: "(1)"},
+			{input: "(take 3 (filter (fn [x] (= 0 (- x (* 2 (/ x 2))))) (range)))", output: "(0 2 4)"},
+			{input: "(reduce + [1 2 3])", output: "6"},
+			{input: "(reduce + 10 (range 4))", output: "16"},
+			{input: "(reduce + [])", output: "0"},
+			{input: "(reduce + 1)", err: "reduce: argument 2 expected sequence, got integer"},
+			{input: `(reduce str "" "abc")`, output: `"abc"`},
+			{input: "(take 2 (range))", output: "(0 1)"},
+			{input: "(take 5 [1 2])", output: "(1 2)"},
+			{input: "(drop 2 [1 2 3])", output: "(3)"},
+			{input: "(first (drop 5 (range)))", output: "5"},
+			{input: "(take-while (fn [x] (< x 3)) (range))", output: "(0 1 2)"},
+
+			{input: "(partition 2 (range 5))", output: "((0 1) (2 3))"},
+			{input: "(partition 2 1 [1 2 3])", output: "((1 2) (2 3))"},
+			{input: "(take 2 (partition 3 (range)))", output: "((0 1 2) (3 4 5))"},
+			{input: "(partition 0 [1])", err: "partition: size and step must be positive"},
+			{input: "(group-by (fn [x] (> x 1)) [1 2 3])", output: "{false [1] true [2 3]}"},
+			{input: `(frequencies "abca")`, output: `{"a" 2 "b" 1 "c" 1}`},
+			{input: "(distinct [1 2 1 3 2])", output: "(1 2 3)"},
+			{input: "(take 3 (distinct (cycle [1 2 3])))", output: "(1 2 3)"},
+			{input: "(distinct (list (range 3) (range 5) (list 1) (range 3)))", output: "((0 1 2) (0 1 2 3 4) (1))"},
+			{input: "(frequencies (list (range 1) (range 2)))", output: "{(0) 1 (0 1) 1}"},
+			{input: "(group-by (fn [n] (range n)) (list 1 2 1))", output: "{(0) [1 1] (0 1) [2]}"},
+			{input: "(into [] (distinct) (list (range 1) (range 2) (range 1)))", output: "[(0) (0 1)]"},
+			{input: "(count (distinct (list + - * +)))", output: "3"},
+			{input: "(let [f (fn [x] x)] (count (distinct (list f f (fn [x] x)))))", output: "2"},
+			{input: "(vals (frequencies (list + - +)))", output: "(2 1)"},
+			{input: `(count (distinct (list (ex-info "e" +) (ex-info "e" -) (ex-info "e" +))))`, output: "2"},
+			{input: "(sort [3 1 2])", output: "(1 2 3)"},
+			{input: `(sort ["b" "c" "a"])`, output: `("a" "b" "c")`},
+			{input: "(sort (list 9223372036854775807 (- 0 2) 0))", output: "(-2 0 9223372036854775807)"},
+			{input: "(sort (list 1 (- (- 0 9223372036854775807) 1) 9223372036854775807))", output: "(-9223372036854775808 1 9223372036854775807)"},
+			{input: "(sort > [3 1 2])", output: "(3 2 1)"},
+			{input: "(sort (fn [a b] (- b a)) [3 1 2])", output: "(3 2 1)"},
+			{input: `(sort [1 "a"])`, err: "sort: cannot compare string and integer"},
+			{input: "(reverse [1 2 3])", output: "(3 2 1)"},
+			{input: "(interleave [1 2 3] (repeat :x))", output: "(1 :x 2 :x 3 :x)"},
+			{input: "(zip [1 2] (list :a :b :c))", output: "([1 :a] [2 :b])"},
+			{input: "(map + 1)", err: "map: argument 2 expected sequence, got integer"},
+		}
+
+		for _, c := range cases {
+			res, err := in.Rep(c.input)
+			if c.err != "" {
+				assert.EqualError(t, err, c.err, "%s: %s", name, c.input)
+				continue
+			}
+			assert.NoError(t, err, "%s: %s", name, c.input)
+			assert.Equal(t, c.output, res, "%s: %s", name, c.input)
+		}
+	}
+}
+
+func TestItemKey(t *testing.T) {
+	ctx := context.Background()
+	key := func(item Item) any {
+		k, err := itemKey(ctx, item)
+		assert.NoError(t, err)
+		return k
+	}
+
+	// Go values are keyed like they compare
+	a, b := new(int), new(int)
+	assert.True(t, key(GoValue{Value: a}) == key(GoValue{Value: a}))
+	assert.False(t, key(GoValue{Value: a}) == key(GoValue{Value: b}))
+	s := []int{1}
+	assert.False(t, key(GoValue{Value: s}) == key(GoValue{Value: s}))
+}
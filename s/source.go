@@ -0,0 +1,41 @@
+package s
+
+import (
+	"fmt"
+)
+
+// Position is a location of a form in source code
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// IsValid returns true if position is known
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "unknown"
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// withPosition returns list remembering where it was read from
+func withPosition(list List, pos Position) List {
+	list.pos = &pos
+	return list
+}
+
+// PositionOf returns position given list was read from
+func PositionOf(list List) (Position, bool) {
+	if list.pos == nil {
+		return Position{}, false
+	}
+	return *list.pos, true
+}
@@ -0,0 +1,93 @@
+package s
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Frame is a single entry of slang call stack
+type Frame struct {
+	// Name of called function, `fn` for anonymous ones
+	Name string
+	// Position of the call-site, invalid when unknown
+	Position Position
+}
+
+func (f Frame) String() string {
+	if !f.Position.IsValid() {
+		return fmt.Sprintf("at %s", f.Name)
+	}
+	return fmt.Sprintf("at %s (%s)", f.Name, f.Position)
+}
+
+// maxFrames limits length of recorded call stack, deep recursion keeps
+// only innermost frames
+const maxFrames = 64
+
+// EvalError is an error returned from Eval together with slang call
+// stack at the moment of failure, innermost frame first
+type EvalError struct {
+	Err   error
+	Stack []Frame
+	// Number of outer frames left out of Stack
+	Dropped int
+}
+
+func (e *EvalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// Trace returns a readable call stack, one frame per line
+func (e *EvalError) Trace() string {
+	lines := make([]string, len(e.Stack))
+	for i, frame := range e.Stack {
+		lines[i] = "  " + frame.String()
+	}
+	if e.Dropped > 0 {
+		lines = append(lines, fmt.Sprintf("  ... %d more", e.Dropped))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// callName returns function name shown in stack frames for call head
+func callName(head Item) string {
+	switch h := head.(type) {
+	case Symbol:
+		return h.Value
+	case Local:
+		return h.Name
+	default:
+		return "fn"
+	}
+}
+
+// withFrame records failed call of a function in error stack
+func withFrame(err error, name string, call List) error {
+	frame := Frame{Name: name}
+	if pos, ok := PositionOf(call); ok {
+		frame.Position = pos
+	}
+	return pushFrame(err, frame)
+}
+
+// pushFrame appends frame of a failed call to error stack. Errors can be
+// shared, e.g. by evaluations waiting for one future, so the frame is
+// added to a copy.
+func pushFrame(err error, frame Frame) error {
+	if evalErr, ok := err.(*EvalError); ok {
+		copied := *evalErr
+		if len(copied.Stack) < maxFrames {
+			copied.Stack = append(slices.Clip(copied.Stack), frame)
+		} else {
+			copied.Dropped++
+		}
+		return &copied
+	}
+
+	return &EvalError{Err: err, Stack: []Frame{frame}}
+}
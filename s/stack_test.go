@@ -0,0 +1,90 @@
+package s
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRep_Stack(t *testing.T) {
+	_, err := Rep("(set stack-inner (fn [a] (+ a missing)))")
+	assert.NoError(t, err)
+	_, err = Rep("(set stack-outer (fn [a]\n  (stack-inner a)))")
+	assert.NoError(t, err)
+
+	_, err = Rep("(+ 1 (stack-outer 2))")
+	assert.EqualError(t, err, "missing is undefined")
+
+	var evalErr *EvalError
+	if assert.True(t, errors.As(err, &evalErr)) {
+		assert.Equal(t, []Frame{
+			{Name: "stack-inner", Position: Position{Line: 2, Column: 3}},
+			{Name: "stack-outer", Position: Position{Line: 1, Column: 6}},
+		}, evalErr.Stack)
+		assert.Equal(t, "  at stack-inner (2:3)\n  at stack-outer (1:6)", evalErr.Trace())
+	}
+}
+
+func TestRep_StackAnonymous(t *testing.T) {
+	_, err := Rep("((fn [] (throw 1)))")
+
+	var evalErr *EvalError
+	if assert.True(t, errors.As(err, &evalErr)) {
+		assert.Equal(t, []Frame{
+			{Name: "throw", Position: Position{Line: 1, Column: 9}},
+			{Name: "fn", Position: Position{Line: 1, Column: 1}},
+		}, evalErr.Stack)
+	}
+
+	var ex *Exception
+	assert.True(t, errors.As(err, &ex))
+}
+
+func TestPositionOf(t *testing.T) {
+	r := NewFileReader("core.slang")
+	item, err := r.Parse("\n  (+ 1\n     (* 2 3))")
+	assert.NoError(t, err)
+
+	list := item.(List)
+	pos, ok := PositionOf(list)
+	assert.True(t, ok)
+	assert.Equal(t, Position{File: "core.slang", Line: 2, Column: 3}, pos)
+	assert.Equal(t, "core.slang:2:3", pos.String())
+
+	pos, ok = PositionOf(list.Value[2].(List))
+	assert.True(t, ok)
+	assert.Equal(t, Position{File: "core.slang", Line: 3, Column: 6}, pos)
+
+	_, ok = PositionOf(List{Value: []Item{Integer{Value: 1}}})
+	assert.False(t, ok)
+}
+
+func TestRep_StackShared(t *testing.T) {
+	in := NewInterpreter(Options{})
+	_, err := in.EvalString("(set failed (go (throw :boom)))\n(set wait (fn [] @failed))")
+	assert.NoError(t, err)
+
+	// Derefs of one failed future each get their own stack
+	stack := func() []Frame {
+		_, err := in.Rep("(wait)")
+		var evalErr *EvalError
+		if assert.True(t, errors.As(err, &evalErr)) {
+			return evalErr.Stack
+		}
+		return nil
+	}
+	first := stack()
+	assert.Equal(t, first, stack())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, first, stack())
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,99 @@
+package s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+)
+
+// initOS sets up access to files, environment and processes
+func (in *Interpreter) initOS() {
+	in.env.DefineBuiltin("load-file", Signature{Params: []Type{TypeString}}, func(ctx context.Context, args []Item) (Item, error) {
+		result, _, err := in.loadFile(ctx, args[0].(String).Value, in.Namespace())
+		return result, err
+	})
+
+	in.env.DefineBuiltin("slurp", Signature{Params: []Type{TypeString}}, func(ctx context.Context, args []Item) (Item, error) {
+		data, err := os.ReadFile(args[0].(String).Value)
+		if err != nil {
+			return nil, err
+		}
+		return String{Value: string(data)}, nil
+	})
+
+	in.env.DefineBuiltin("spit", Signature{Params: []Type{TypeString, TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		content, err := display(ctx, args[1], false)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(args[0].(String).Value, []byte(content), 0o644); err != nil {
+			return nil, err
+		}
+		return Nil{}, nil
+	})
+
+	in.env.DefineBuiltin("getenv", Signature{Params: []Type{TypeString}}, func(ctx context.Context, args []Item) (Item, error) {
+		if value, ok := os.LookupEnv(args[0].(String).Value); ok {
+			return String{Value: value}, nil
+		}
+		return Nil{}, nil
+	})
+
+	in.env.DefineBuiltin("sh", Signature{Params: []Type{TypeString}, Rest: TypeString}, func(ctx context.Context, args []Item) (Item, error) {
+		argv := make([]string, len(args))
+		for i, arg := range args {
+			argv[i] = arg.(String).Value
+		}
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		exit := 0
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return nil, context.Cause(ctx)
+			}
+
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return nil, err
+			}
+			exit = exitErr.ExitCode()
+		}
+
+		return Hash{}.
+			Add(KeyValue{Key: NewKeyword("exit"), Value: Integer{Value: int64(exit)}}).
+			Add(KeyValue{Key: NewKeyword("out"), Value: String{Value: stdout.String()}}).
+			Add(KeyValue{Key: NewKeyword("err"), Value: String{Value: stderr.String()}}), nil
+	})
+}
+
+// initNet sets up network access
+func (e *Env) initNet() {
+	e.DefineBuiltin("http-get", Signature{Params: []Type{TypeString}}, func(ctx context.Context, args []Item) (Item, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, args[0].(String).Value, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		return Hash{}.
+			Add(KeyValue{Key: NewKeyword("status"), Value: Integer{Value: int64(resp.StatusCode)}}).
+			Add(KeyValue{Key: NewKeyword("body"), Value: String{Value: string(body)}}), nil
+	})
+}
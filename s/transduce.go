@@ -0,0 +1,338 @@
+package s
+
+import (
+	"context"
+	"fmt"
+)
+
+// Reduced wraps result of a reduction which should stop early
+type Reduced struct {
+	DefaultItem
+	Value Item
+}
+
+func (self Reduced) Equal(i Item) Item {
+	if v, ok := i.(Reduced); ok {
+		return self.Value.Equal(v.Value)
+	}
+	return False{}
+}
+
+// ensureReduced wraps acc unless it is already reduced
+func ensureReduced(acc Item) Item {
+	if _, ok := acc.(Reduced); ok {
+		return acc
+	}
+	return Reduced{Value: acc}
+}
+
+// stepFunc adds input to result acc of a reduction
+type stepFunc func(ctx context.Context, acc Item, input Item) (Item, error)
+
+// transducer returns a transducer, a function turning reducing function
+// rf into another one. Reducing functions return the initial result
+// without arguments, complete the result with one and add an input to
+// it with two. Transducers only change the last, newStep is called for
+// every rf so the step it returns may keep state.
+func transducer(name string, newStep func(rf Func) stepFunc) Func {
+	return Builtin(name, Signature{Params: []Type{TypeFunc}}, func(ctx context.Context, args []Item) (Item, error) {
+		rf := args[0].(Func)
+		step := newStep(rf)
+
+		return Func{Value: func(ctx context.Context, args []Item) (Item, error) {
+			switch len(args) {
+			case 0, 1:
+				return apply(ctx, rf, args)
+			case 2:
+				return step(ctx, args[0], args[1])
+			default:
+				return nil, fmt.Errorf("%s: expected 0 to 2 arguments, got %d", name, len(args))
+			}
+		}}, nil
+	})
+}
+
+func mapXform(fn Func) Func {
+	return transducer("map", func(rf Func) stepFunc {
+		return func(ctx context.Context, acc Item, input Item) (Item, error) {
+			value, err := apply(ctx, fn, []Item{input})
+			if err != nil {
+				return nil, err
+			}
+			return apply(ctx, rf, []Item{acc, value})
+		}
+	})
+}
+
+// filterXform passes inputs for which fn returns keep
+func filterXform(fn Func, keep bool) Func {
+	return transducer("filter", func(rf Func) stepFunc {
+		return func(ctx context.Context, acc Item, input Item) (Item, error) {
+			value, err := apply(ctx, fn, []Item{input})
+			if err != nil || Truthy(value) != keep {
+				return acc, err
+			}
+			return apply(ctx, rf, []Item{acc, input})
+		}
+	})
+}
+
+func takeXform(n int64) Func {
+	return transducer("take", func(rf Func) stepFunc {
+		left := n
+		return func(ctx context.Context, acc Item, input Item) (Item, error) {
+			if left <= 0 {
+				return ensureReduced(acc), nil
+			}
+
+			left--
+			acc, err := apply(ctx, rf, []Item{acc, input})
+			if err != nil || left > 0 {
+				return acc, err
+			}
+			// Stop right away rather than on the next input
+			return ensureReduced(acc), nil
+		}
+	})
+}
+
+func dropXform(n int64) Func {
+	return transducer("drop", func(rf Func) stepFunc {
+		left := n
+		return func(ctx context.Context, acc Item, input Item) (Item, error) {
+			if left > 0 {
+				left--
+				return acc, nil
+			}
+			return apply(ctx, rf, []Item{acc, input})
+		}
+	})
+}
+
+func takeWhileXform(fn Func) Func {
+	return transducer("take-while", func(rf Func) stepFunc {
+		return func(ctx context.Context, acc Item, input Item) (Item, error) {
+			value, err := apply(ctx, fn, []Item{input})
+			if err != nil {
+				return nil, err
+			}
+			if !Truthy(value) {
+				return ensureReduced(acc), nil
+			}
+			return apply(ctx, rf, []Item{acc, input})
+		}
+	})
+}
+
+func distinctXform() Func {
+	return transducer("distinct", func(rf Func) stepFunc {
+		seen := make(map[any]bool)
+		return func(ctx context.Context, acc Item, input Item) (Item, error) {
+			key, err := itemKey(ctx, input)
+			if err != nil || seen[key] {
+				return acc, err
+			}
+			seen[key] = true
+			return apply(ctx, rf, []Item{acc, input})
+		}
+	})
+}
+
+// xformed applies transducer xform to reducing function rf
+func xformed(ctx context.Context, name string, xform Func, rf Func) (Func, error) {
+	res, err := apply(ctx, xform, []Item{rf})
+	if err != nil {
+		return Func{}, err
+	}
+
+	fn, ok := res.(Func)
+	if !ok {
+		return Func{}, fmt.Errorf("%s: transducer returned %s, expected function", name, typeName(res))
+	}
+	return fn, nil
+}
+
+// collector returns reducing function appending inputs to items, its
+// result stays nil
+func collector(items *[]Item) Func {
+	return Func{Value: func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) == 2 {
+			*items = append(*items, args[1])
+			if err := checkSize(ctx, len(*items)); err != nil {
+				return nil, err
+			}
+		}
+		return Nil{}, nil
+	}}
+}
+
+// xformState is shared by nodes of a sequence produced by a transducer
+type xformState struct {
+	rf      Func
+	src     Seq
+	pending []Item
+	done    bool
+}
+
+// xformSeq lazily passes items of state source through its reducing
+// function, which collects them in pending
+func xformSeq(state *xformState) Seq {
+	return NewLazySeq(func(ctx context.Context) (Item, error) {
+		for len(state.pending) == 0 && !state.done {
+			first, rest, ok, err := uncons(ctx, state.src)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				state.src = rest
+				res, err := apply(ctx, state.rf, []Item{Nil{}, first})
+				if err != nil {
+					return nil, err
+				}
+				_, ok = res.(Reduced)
+				ok = !ok
+			}
+			if !ok {
+				state.done = true
+				if _, err := apply(ctx, state.rf, []Item{Nil{}}); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if len(state.pending) == 0 {
+			return emptyList, nil
+		}
+		head := state.pending[0]
+		state.pending = state.pending[1:]
+		return Cons{Head: head, Tail: xformSeq(state)}, nil
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// initTransducers sets up transducers and functions applying them,
+// transducers themselves come from sequence functions called without
+// a collection
+func (e *Env) initTransducers() {
+	e.DefineBuiltin("reduced", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		return Reduced{Value: args[0]}, nil
+	})
+
+	e.DefineBuiltin("reduced?", Signature{Params: []Type{TypeAny}}, func(ctx context.Context, args []Item) (Item, error) {
+		if _, ok := args[0].(Reduced); ok {
+			return True{}, nil
+		}
+		return False{}, nil
+	})
+
+	e.DefineBuiltin("comp", Signature{Rest: TypeFunc}, func(ctx context.Context, args []Item) (Item, error) {
+		fns := make([]Func, len(args))
+		for i, arg := range args {
+			fns[i] = arg.(Func)
+		}
+
+		return Func{Value: func(ctx context.Context, args []Item) (Item, error) {
+			if len(fns) == 0 {
+				if len(args) != 1 {
+					return nil, fmt.Errorf("comp: expected 1 argument, got %d", len(args))
+				}
+				return args[0], nil
+			}
+
+			// Functions are applied right to left
+			res, err := apply(ctx, fns[len(fns)-1], args)
+			for i := len(fns) - 2; i >= 0 && err == nil; i-- {
+				res, err = apply(ctx, fns[i], []Item{res})
+			}
+			return res, err
+		}}, nil
+	})
+
+	e.DefineBuiltin("transduce", Signature{Params: []Type{TypeFunc, TypeFunc, TypeAny}, Optional: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		f := args[1].(Func)
+		rf, err := xformed(ctx, "transduce", args[0].(Func), f)
+		if err != nil {
+			return nil, err
+		}
+
+		var acc Item
+		coll := args[len(args)-1]
+		if len(args) == 4 {
+			acc = args[2]
+		} else if acc, err = apply(ctx, f, nil); err != nil {
+			return nil, err
+		}
+
+		seq, ok := coll.(Seq)
+		if !ok {
+			return nil, fmt.Errorf("transduce: argument %d expected sequence, got %s", len(args), typeName(coll))
+		}
+		if acc, err = reduceSeq(ctx, rf, acc, seq); err != nil {
+			return nil, err
+		}
+		return apply(ctx, rf, []Item{acc})
+	})
+
+	e.DefineBuiltin("into", Signature{Params: []Type{TypeAny, TypeAny}, Optional: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		coll := args[len(args)-1]
+		seq, ok := coll.(Seq)
+		if !ok {
+			return nil, fmt.Errorf("into: argument %d expected sequence, got %s", len(args), typeName(coll))
+		}
+
+		var items []Item
+		var err error
+		if len(args) == 2 {
+			items, err = realize(ctx, seq)
+		} else {
+			xform, ok := args[1].(Func)
+			if !ok {
+				return nil, fmt.Errorf("into: argument 2 expected function, got %s", typeName(args[1]))
+			}
+
+			var rf Func
+			if rf, err = xformed(ctx, "into", xform, collector(&items)); err == nil {
+				var acc Item
+				if acc, err = reduceSeq(ctx, rf, Nil{}, seq); err == nil {
+					_, err = apply(ctx, rf, []Item{acc})
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Items are added at once, so vectors are copied only once
+		res, err := conj(ctx, args[0], items)
+		if err != nil {
+			return nil, fmt.Errorf("into: %w", err)
+		}
+		return res, nil
+	})
+
+	e.DefineBuiltin("sequence", Signature{Params: []Type{TypeAny}, Optional: []Type{TypeSeq}}, func(ctx context.Context, args []Item) (Item, error) {
+		coll := args[len(args)-1]
+		seq, ok := coll.(Seq)
+		if !ok {
+			return nil, fmt.Errorf("sequence: argument %d expected sequence, got %s", len(args), typeName(coll))
+		}
+		if len(args) == 1 {
+			return seq, nil
+		}
+
+		xform, ok := args[0].(Func)
+		if !ok {
+			return nil, fmt.Errorf("sequence: argument 1 expected function, got %s", typeName(args[0]))
+		}
+
+		state := &xformState{src: seq}
+		rf, err := xformed(ctx, "sequence", xform, collector(&state.pending))
+		if err != nil {
+			return nil, err
+		}
+		state.rf = rf
+		return xformSeq(state), nil
+	})
+}
@@ -0,0 +1,72 @@
+package s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransduce(t *testing.T) {
+	for name, backend := range backends {
+		in := NewInterpreter(Options{Backend: backend})
+
+		cases := []struct {
+			input  string
+			output string
+			err    string
+		}{
+			{input: "(set inc (fn [x] (+ x 1)))", output: "function"},
+			{input: "(set odd (fn [x] (= 1 (- x (* 2 (/ x 2))))))", output: "function"},
+
+			{input: "(transduce (map inc) + [1 2 3])", output: "9"},
+			{input: "(transduce (filter odd) + 100 (range 10))", output: "125"},
+			{input: "(transduce (comp (map inc) (filter odd) (take 3)) conj [] (range))", output: "[1 3 5]"},
+			{input: "(transduce (take-while (fn [x] (< x 4))) conj (list) (range))", output: "(3 2 1 0)"},
+			{input: "(transduce (map inc) + 1)", err: "transduce: argument 3 expected sequence, got integer"},
+			{input: "(into [] (comp (drop 2) (remove odd)) (range 10))", output: "[2 4 6 8]"},
+			{input: "(into [0] (list 1 2))", output: "[0 1 2]"},
+			{input: "(into {} (map (fn [k] (list k 1))) [:a :b])", output: "{:a 1 :b 1}"},
+			{input: "(into (list) (distinct) [1 2 1])", output: "(2 1)"},
+			{input: "(into 1 [2])", err: "into: expected collection, got integer"},
+			{input: "(sequence (comp (map inc) (take 3)) (range))", output: "(1 2 3)"},
+			{input: "(take 2 (sequence (filter odd) (range)))", output: "(1 3)"},
+			{input: "(sequence (map inc) [])", output: "()"},
+			{input: "(sequence [1 2])", output: "[1 2]"},
+
+			{input: "((comp inc inc) 1)", output: "3"},
+			{input: "((comp) 1)", output: "1"},
+			{input: "((comp str +) 1 2)", output: `"3"`},
+			{input: "(reduce (fn [acc x] (if (> x 2) (reduced acc) (+ acc x))) (range))", output: "3"},
+			{input: "(reduced? (reduced 1))", output: "true"},
+			{input: "(reduced 1)", output: "#<Reduced 1>"},
+
+			// Transducers can be applied to reducing functions directly
+			{input: "(((map inc) +) 1 2)", output: "4"},
+			{input: "(((map inc) +) 5)", output: "5"},
+		}
+
+		for _, c := range cases {
+			res, err := in.Rep(c.input)
+			if c.err != "" {
+				assert.EqualError(t, err, c.err, "%s: %s", name, c.input)
+				continue
+			}
+			assert.NoError(t, err, "%s: %s", name, c.input)
+			assert.Equal(t, c.output, res, "%s: %s", name, c.input)
+		}
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	setup := `
+(set coll (into [] (range 10000)))
+(set inc (fn [x] (+ x 1)))
+(set even (fn [x] (= x (* 2 (/ x 2)))))`
+
+	b.Run("chained", func(b *testing.B) {
+		benchmarkEval(b, Options{}, setup, "(reduce + (take 1000 (filter even (map inc coll))))")
+	})
+	b.Run("transduce", func(b *testing.B) {
+		benchmarkEval(b, Options{}, setup, "(transduce (comp (map inc) (filter even) (take 1000)) + coll)")
+	})
+}
@@ -0,0 +1,220 @@
+package s
+
+import (
+	"context"
+	"fmt"
+)
+
+// Run executes top level chunk in given environment
+func (self *Chunk) Run(ctx context.Context, env *Env) (Item, error) {
+	ctx, cancel := startBudget(ctx, limitsOf(env))
+	defer cancel()
+
+	if self.Slots > 0 {
+		env = env.newFrame(self.Slots)
+	}
+	return run(ctx, self, env)
+}
+
+// closure creates function of chunk closed over given environment
+func closure(chunk *Chunk, env *Env) Func {
+	return Func{Value: func(ctx context.Context, args []Item) (Item, error) {
+		if len(args) < chunk.Required || (!chunk.Variadic && len(args) > chunk.Required) {
+			return nil, fmt.Errorf("wrong number of args (%d) passed to fn", len(args))
+		}
+
+		b := budgetOf(ctx)
+		if err := b.enter(); err != nil {
+			return nil, err
+		}
+		defer b.leave()
+
+		frame := env.newFrame(chunk.Slots)
+		if err := bind(ctx, chunk.Params, List{Value: args}, frame); err != nil {
+			return nil, err
+		}
+
+		return run(ctx, chunk, frame)
+	}}
+}
+
+// run is the VM loop, it executes chunk code using env as the frame
+func run(ctx context.Context, chunk *Chunk, env *Env) (Item, error) {
+	code, consts := chunk.Code, chunk.Consts
+	stack := make([]Item, 0, 8)
+
+	pc := 0
+	operand := func() int {
+		o := int(code[pc])<<8 | int(code[pc+1])
+		pc += 2
+		return o
+	}
+	pop := func() Item {
+		item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return item
+	}
+
+	for {
+		op := Opcode(code[pc])
+		pc++
+
+		switch op {
+		case OpConst:
+			stack = append(stack, consts[operand()])
+
+		case OpGlobal:
+			item, err := env.Get(consts[operand()].(Symbol).Value)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, item)
+
+		case OpLocal:
+			l := consts[operand()].(Local)
+
+			frame := env
+			for i := 0; i < l.Depth; i++ {
+				frame = frame.parent
+			}
+
+			item := frame.slots[l.Index]
+			if item == nil {
+				return nil, fmt.Errorf("%s is undefined", l.Name)
+			}
+			stack = append(stack, item)
+
+		case OpDefine:
+			name := consts[operand()].(Symbol).Value
+			env.globals().Define(name, stack[len(stack)-1])
+
+		case OpBind:
+			pattern := consts[operand()]
+			if err := bind(ctx, pattern, pop(), env); err != nil {
+				return nil, err
+			}
+
+		case OpPop:
+			pop()
+
+		case OpJump:
+			pc = operand()
+
+		case OpJumpFalse:
+			target := operand()
+			if !Truthy(pop()) {
+				pc = target
+			}
+
+		case OpJumpFalseKeep, OpJumpTrueKeep:
+			target := operand()
+			if Truthy(stack[len(stack)-1]) == (op == OpJumpTrueKeep) {
+				pc = target
+			} else {
+				pop()
+			}
+
+		case OpMatch:
+			candidates, next := consts[operand()].(List), operand()
+
+			// Continue with the clause body on match, next clause otherwise
+			value := stack[len(stack)-1]
+			matched := false
+			for _, c := range candidates.Value {
+				if c.Equal(value).IsTrue() {
+					matched = true
+					break
+				}
+			}
+
+			if matched {
+				pop()
+			} else {
+				pc = next
+			}
+
+		case OpNoMatch:
+			str, err := printContext(ctx, pop())
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("no matching clause: %s", str)
+
+		case OpCall:
+			n, site := operand(), operand()
+
+			args := make([]Item, n)
+			copy(args, stack[len(stack)-n:])
+			stack = stack[:len(stack)-n]
+
+			fn := pop()
+			f, ok := callable(fn)
+			if !ok {
+				return nil, fmt.Errorf("Unexpected type of %v", fn)
+			}
+
+			if err := step(ctx); err != nil {
+				return nil, err
+			}
+
+			val, err := f.Value(ctx, args)
+			if err != nil {
+				return nil, pushFrame(err, chunk.Calls[site])
+			}
+			stack = append(stack, val)
+
+		case OpClosure:
+			stack = append(stack, closure(consts[operand()].(*Chunk), env))
+
+		case OpTry:
+			body, catch, finally := operand(), operand(), operand()
+
+			var handler func(Item) (Item, error)
+			if catch != noChunk {
+				c := consts[catch].(*Chunk)
+				handler = func(value Item) (Item, error) {
+					if err := bind(ctx, c.Params, value, env); err != nil {
+						return nil, err
+					}
+					return run(ctx, c, env)
+				}
+			}
+
+			var cleanup func() error
+			if finally != noChunk {
+				cleanup = func() error {
+					_, err := run(ctx, consts[finally].(*Chunk), env)
+					return err
+				}
+			}
+
+			val, err := runTry(func() (Item, error) {
+				return run(ctx, consts[body].(*Chunk), env)
+			}, handler, cleanup)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, val)
+
+		case OpRequire, OpRefer:
+			args := consts[operand()].(List).Value
+
+			special := evalRequire
+			if op == OpRefer {
+				special = evalRefer
+			}
+
+			val, err := special(ctx, args, env)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, val)
+
+		case OpReturn:
+			return pop(), nil
+
+		default:
+			return nil, fmt.Errorf("unknown opcode %d at %d", op, pc-1)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package s
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunk_Run(t *testing.T) {
+	in := NewInterpreter(Options{})
+
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{"(set inc (fn [x] (+ x 1)))", "function"},
+		{"(inc 1)", "2"},
+		{"(let [a 1 [b c] (list 2 3)] (+ a b c))", "6"},
+		{"(if (= 1 2) :yes :no)", ":no"},
+		{"(case 3 1 :one (2 3) :few :many)", ":few"},
+		{"(try (throw 1) (catch e (inc e)))", "2"},
+	}
+
+	for _, c := range cases {
+		form, err := read(c.input)
+		assert.NoError(t, err)
+
+		chunk, err := Compile(form)
+		assert.NoError(t, err)
+
+		res, err := chunk.Run(context.Background(), in.Namespace().Env())
+		assert.NoError(t, err)
+
+		str, err := print(res)
+		assert.NoError(t, err)
+		assert.Equal(t, c.output, str, "%s should return %s", c.input, c.output)
+	}
+}
+
+func TestChunk_Disassemble(t *testing.T) {
+	form, err := read("(set inc (fn [x] (if x (+ x 1) 0)))")
+	assert.NoError(t, err)
+
+	chunk, err := Compile(form)
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, chunk.Disassemble(&out))
+	assert.Equal(t, `chunk main
+  0000 CLOSURE          0 ; inc
+  0003 DEFINE           1 ; inc
+  0006 RETURN
+
+chunk inc (slots 1)
+  0000 LOCAL            0 ; x
+  0003 JUMP_FALSE       23
+  0006 GLOBAL           1 ; +
+  0009 LOCAL            2 ; x
+  0012 CONST            3 ; 1
+  0015 CALL             2 0 ; at + (1:24)
+  0020 JUMP             26
+  0023 CONST            4 ; 0
+  0026 RETURN
+`, out.String())
+}
+
+func TestModule(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"util/math.slang": `
+(ns util.math)
+(set square (fn [x] (* x x)))
+(set fail (fn [] (throw :boom)))
+`,
+		"main.slang": `
+(ns app (:require [util.math :as m]))
+(m/square 7)
+`,
+	})
+
+	// Compile sources next to each other and drop util source, so require
+	// finds only the compiled module
+	for _, name := range []string{"util/math", "main"} {
+		src := filepath.Join(dir, name+".slang")
+		assert.NoError(t, CompileFile(src, src+"c"))
+	}
+	assert.NoError(t, os.Remove(filepath.Join(dir, "util/math.slang")))
+
+	in := NewInterpreter(Options{Path: []string{dir}})
+	res, err := in.LoadFile(filepath.Join(dir, "main.slangc"))
+	assert.NoError(t, err)
+	assert.Equal(t, Integer{Value: 49}, res)
+
+	_, err = in.EvalString("(require [util.math :as m]) (m/fail)")
+	if assert.Error(t, err) {
+		evalErr, ok := err.(*EvalError)
+		if assert.True(t, ok) {
+			source := filepath.Join(dir, "util/math.slang")
+			assert.Equal(t, "  at throw ("+source+":4:18)\n  at m/fail (1:29)", evalErr.Trace())
+		}
+	}
+}
+
+func TestModule_ReadWrite(t *testing.T) {
+	m, err := CompileModule(`
+(ns shapes)
+(set area (fn [{:keys [w h] :or {h (+ 1 1)}}] (* w h)))
+(case "b" "a" 1 ("b" "c") [2 :two] nil)
+`, "shapes.slang")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	data := buf.Bytes()
+	loaded, err := ReadModule(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	var before, after strings.Builder
+	assert.NoError(t, m.Disassemble(&before))
+	assert.NoError(t, loaded.Disassemble(&after))
+	assert.Equal(t, before.String(), after.String())
+
+	in := NewInterpreter(Options{})
+	var res Item
+	for _, form := range loaded.Forms {
+		res, _, err = in.evalIn(context.Background(), in.Namespace(), form)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, Vector{Value: []Item{Integer{Value: 2}, NewKeyword("two")}}, res)
+
+	_, err = ReadModule(strings.NewReader("(+ 1 2)"))
+	assert.EqualError(t, err, "not a compiled slang module")
+
+	_, err = ReadModule(bytes.NewReader(data[:len(data)-3]))
+	assert.Error(t, err)
+
+	bad := &Module{Forms: []Item{&Chunk{Name: "bad", Code: []byte{byte(OpJump), 0xff, 0, byte(OpReturn)}}}}
+	buf.Reset()
+	_, err = bad.WriteTo(&buf)
+	assert.NoError(t, err)
+	_, err = ReadModule(&buf)
+	assert.EqualError(t, err, "corrupted module: invalid jump to 65280 in chunk bad")
+}
+
+func TestModule_Verify(t *testing.T) {
+	op := func(code Opcode, args ...byte) []byte { return append([]byte{byte(code)}, args...) }
+	chunk := func(consts []Item, code ...[]byte) *Chunk {
+		c := &Chunk{Name: "bad", Consts: consts, Calls: []Frame{{}}}
+		for _, ins := range code {
+			c.Code = append(c.Code, ins...)
+		}
+		return c
+	}
+
+	cases := map[string]*Chunk{
+		"stack underflow at 0 in chunk bad": chunk(nil, op(OpPop), op(OpReturn)),
+		"invalid local x in chunk bad": chunk([]Item{Local{Name: "x", Depth: 3, Index: 9}},
+			op(OpLocal, 0, 0), op(OpReturn)),
+		"stack underflow at 3 in chunk bad": chunk([]Item{Nil{}},
+			op(OpConst, 0, 0), op(OpCall, 0, 5, 0, 0), op(OpReturn)),
+		"invalid jump to 1 in chunk bad": chunk([]Item{Nil{}},
+			op(OpConst, 0, 0), op(OpJump, 0, 1), op(OpReturn)),
+		"inconsistent stack at 9 in chunk bad": chunk([]Item{Nil{}},
+			op(OpConst, 0, 0), op(OpJumpFalse, 0, 9), op(OpConst, 0, 0), op(OpReturn)),
+		"chunk bad does not end with return": chunk([]Item{Nil{}}, op(OpConst, 0, 0)),
+	}
+
+	// Defaults of patterns run in the tree walker
+	defaults := map[string]Item{
+		"invalid form (let 1) in chunk bad": List{Value: []Item{NewSymbol("let"), Integer{Value: 1}}},
+		"invalid form (if 1) in chunk bad": Lambda{Body: []Item{
+			List{Value: []Item{NewSymbol("do"), List{Value: []Item{NewSymbol("if"), Integer{Value: 1}}}}},
+		}},
+		"invalid form (set 1 2) in chunk bad": List{Value: []Item{
+			NewSymbol("try"), List{Value: []Item{NewSymbol("set"), Integer{Value: 1}, Integer{Value: 2}}},
+		}},
+	}
+	for msg, def := range defaults {
+		pattern := Hash{Value: []KeyValue{
+			{Key: Local{Name: "a"}, Value: NewKeyword("a")},
+			{Key: NewKeyword("or"), Value: Hash{Value: []KeyValue{{Key: NewSymbol("a"), Value: def}}}},
+		}}
+		c := chunk([]Item{Nil{}, pattern}, op(OpConst, 0, 0), op(OpBind, 0, 1), op(OpConst, 0, 0), op(OpReturn))
+		c.Slots = 1
+		cases[msg] = c
+	}
+
+	for msg, c := range cases {
+		var buf bytes.Buffer
+		_, err := (&Module{Forms: []Item{c}}).WriteTo(&buf)
+		assert.NoError(t, err)
+		_, err = ReadModule(&buf)
+		assert.EqualError(t, err, "corrupted module: "+msg)
+	}
+
+	// Counts can't make the decoder allocate beyond its input
+	data := binary.AppendUvarint(append([]byte{}, moduleMagic...), 1<<24)
+	_, err := ReadModule(bytes.NewReader(data))
+	assert.EqualError(t, err, "corrupted module: count 16777216 exceeds input")
+}